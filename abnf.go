@@ -0,0 +1,411 @@
+package abstract
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//// ABNF (RFC 5234).
+
+// The core rules of RFC 5234, Appendix B.1.
+var (
+	ALPHA  = charRanges([2]rune{'A', 'Z'}, [2]rune{'a', 'z'})
+	BIT    = charRanges([2]rune{'0', '1'})
+	CHAR   = charRanges([2]rune{0x01, 0x7f})
+	CR     = Lex("\r")
+	LF     = Lex("\n")
+	CRLF   = And(CR, LF)
+	CTL    = charRanges([2]rune{0x00, 0x1f}, [2]rune{0x7f, 0x7f})
+	DIGIT  = charRanges([2]rune{'0', '9'})
+	DQUOTE = Lex("\"")
+	HEXDIG = charRanges([2]rune{'0', '9'}, [2]rune{'A', 'F'}, [2]rune{'a', 'f'})
+	HTAB   = Lex("\t")
+	SP     = Lex(" ")
+	WSP    = OneOf(SP, HTAB)
+	LWSP   = Maybe(Many(OneOf(WSP, And(CRLF, WSP))))
+	OCTET  = charRanges([2]rune{0x00, 0xff})
+	VCHAR  = charRanges([2]rune{0x21, 0x7e})
+)
+
+func coreRules() map[string]*Lexer {
+	return map[string]*Lexer{
+		"alpha":  ALPHA,
+		"bit":    BIT,
+		"char":   CHAR,
+		"cr":     CR,
+		"crlf":   CRLF,
+		"ctl":    CTL,
+		"digit":  DIGIT,
+		"dquote": DQUOTE,
+		"hexdig": HEXDIG,
+		"htab":   HTAB,
+		"lf":     LF,
+		"lwsp":   LWSP,
+		"octet":  OCTET,
+		"sp":     SP,
+		"vchar":  VCHAR,
+		"wsp":    WSP,
+	}
+}
+
+// LoadABNF builds a Lexer for every rule of an ABNF grammar, keyed by the
+// name the rule was first defined with. Rule names are case-insensitive,
+// the core rules are predefined, and numeric values stand for Unicode code
+// points. Since lexers can't refer to themselves, neither can the rules.
+// Input is read as UTF-8, so OCTET matches the characters U+0000 to U+00FF
+// rather than any byte: bytes 0x80 and above only come as part of a
+// character, and on their own don't match at all.
+//
+// Repetitions try every count, like Many, and quoted strings match in
+// any case, US-ASCII letters only, unless they are written %s"...".
+func LoadABNF(text string) (map[string]*Lexer, error) {
+	parser := &abnfParser{ruleParser{text: []rune(text)}}
+	rules := newRuleSet(parser.text, coreRules(), strings.ToLower)
+
+	for {
+		parser.skipLines()
+		if parser.done() {
+			break
+		}
+		start := parser.pos
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		parser.skip()
+		pos := parser.pos
+		incremental := false
+		switch {
+		case strings.HasPrefix(string(parser.text[pos:]), "=/"):
+			parser.pos += 2
+			incremental = true
+		case parser.peek() == '=':
+			parser.pos++
+		default:
+			return nil, parser.error(pos, "expected '=' or '=/' after rule name %q", name)
+		}
+
+		expr, err := parser.alternation()
+		if err != nil {
+			return nil, err
+		}
+		parser.skip()
+		if !parser.done() && parser.peek() != '\n' && parser.peek() != '\r' {
+			return nil, parser.error(parser.pos, "unexpected %q", parser.peek())
+		}
+
+		previous, defined := rules.defs[strings.ToLower(name)]
+		switch {
+		case incremental && !defined:
+			return nil, parser.error(start, "rule %q is extended with '=/' before it is defined", name)
+		case incremental:
+			if previous.kind == ruleOneOf {
+				previous.children = append(previous.children, expr)
+			} else {
+				rules.define(name, &ruleExpr{kind: ruleOneOf, children: []*ruleExpr{previous, expr}, pos: previous.pos})
+			}
+		case defined:
+			return nil, parser.error(start, "rule %q is defined twice, use '=/' to add alternatives", name)
+		default:
+			rules.define(name, expr)
+		}
+	}
+	return rules.build()
+}
+
+type abnfParser struct {
+	ruleParser
+}
+
+// Skips blank lines and lines holding only a comment.
+func (self *abnfParser) skipLines() {
+	for !self.done() {
+		self.skip()
+		if self.done() || (self.peek() != '\n' && self.peek() != '\r') {
+			return
+		}
+		self.pos++
+	}
+}
+
+// Skips spaces and comments, along with the line breaks
+// of rules that go on over several lines.
+func (self *abnfParser) skip() {
+	for !self.done() {
+		switch r := self.peek(); {
+		case r == ' ' || r == '\t':
+			self.pos++
+		case r == ';':
+			for !self.done() && self.peek() != '\n' {
+				self.pos++
+			}
+		case r == '\r' || r == '\n':
+			// Only continue on the next line if it is indented.
+			next := self.pos + 1
+			if r == '\r' && next < len(self.text) && self.text[next] == '\n' {
+				next++
+			}
+			if next >= len(self.text) || (self.text[next] != ' ' && self.text[next] != '\t') {
+				return
+			}
+			self.pos = next
+		default:
+			return
+		}
+	}
+}
+
+func isABNFName(r rune, first bool) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (!first && ((r >= '0' && r <= '9') || r == '-'))
+}
+
+func (self *abnfParser) name() (string, error) {
+	start := self.pos
+	for !self.done() && isABNFName(self.peek(), self.pos == start) {
+		self.pos++
+	}
+	if start == self.pos {
+		return "", self.error(start, "expected a rule name")
+	}
+	return string(self.text[start:self.pos]), nil
+}
+
+func (self *abnfParser) alternation() (*ruleExpr, error) {
+	self.skip()
+	start := self.pos
+	alternatives := []*ruleExpr{}
+	for {
+		expr, err := self.concatenation()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, expr)
+		self.skip()
+		if self.done() || self.peek() != '/' {
+			break
+		}
+		self.pos++
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return &ruleExpr{kind: ruleOneOf, children: alternatives, pos: start}, nil
+}
+
+func (self *abnfParser) concatenation() (*ruleExpr, error) {
+	self.skip()
+	start := self.pos
+	items := []*ruleExpr{}
+	for {
+		self.skip()
+		if self.done() || strings.ContainsRune("/)]\r\n", self.peek()) {
+			break
+		}
+		item, err := self.repetition()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, self.error(self.pos, "expected an element")
+	}
+	return &ruleExpr{kind: ruleAnd, children: items, pos: start}, nil
+}
+
+// Reads the digits at the current position, if there are any.
+func (self *abnfParser) digits() (int, bool) {
+	start := self.pos
+	for !self.done() && self.peek() >= '0' && self.peek() <= '9' {
+		self.pos++
+	}
+	if start == self.pos {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(self.text[start:self.pos]))
+	return n, err == nil
+}
+
+func (self *abnfParser) repetition() (*ruleExpr, error) {
+	start := self.pos
+	min, has_min := self.digits()
+	max := min
+	repeated := has_min
+	if !self.done() && self.peek() == '*' {
+		self.pos++
+		repeated = true
+		if !has_min {
+			min = 0
+		}
+		var has_max bool
+		if max, has_max = self.digits(); !has_max {
+			max = UNBOUNDED
+		}
+	}
+
+	element, err := self.element()
+	if err != nil {
+		return nil, err
+	}
+	if !repeated {
+		return element, nil
+	}
+	if min == 1 && max == 1 {
+		return element, nil
+	}
+	return &ruleExpr{kind: ruleRepeat, min: min, max: max, mode: LAZY, children: []*ruleExpr{element}, pos: start}, nil
+}
+
+func (self *abnfParser) element() (*ruleExpr, error) {
+	start := self.pos
+	if self.done() {
+		return nil, self.error(start, "unexpected end of grammar")
+	}
+	switch r := self.peek(); {
+	case r == '(' || r == '[':
+		self.pos++
+		expr, err := self.alternation()
+		if err != nil {
+			return nil, err
+		}
+		self.skip()
+		closing := map[rune]rune{'(': ')', '[': ']'}[r]
+		if self.done() || self.peek() != closing {
+			return nil, self.error(self.pos, "expected %q", closing)
+		}
+		self.pos++
+		if r == '[' {
+			return &ruleExpr{kind: ruleMaybe, children: []*ruleExpr{expr}, pos: start}, nil
+		}
+		return expr, nil
+	case r == '"':
+		return self.quoted(start, true)
+	case r == '%':
+		return self.numeric(start)
+	case r == '<':
+		return nil, self.error(start, "prose values are not supported")
+	case isABNFName(r, true):
+		name, err := self.name()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleExpr{kind: ruleRef, text: name, pos: start}, nil
+	}
+	return nil, self.error(start, "unexpected %q", self.peek())
+}
+
+func (self *abnfParser) quoted(start int, fold bool) (*ruleExpr, error) {
+	self.pos++
+	from := self.pos
+	for !self.done() && self.peek() != '"' {
+		if self.peek() == '\n' || self.peek() == '\r' {
+			break
+		}
+		self.pos++
+	}
+	if self.done() || self.peek() != '"' {
+		return nil, self.error(start, "unterminated string")
+	}
+	text := string(self.text[from:self.pos])
+	self.pos++
+	if text == "" {
+		return nil, self.error(start, "empty string")
+	}
+	if fold {
+		return asciiFold(text, start), nil
+	}
+	return &ruleExpr{kind: ruleLiteral, text: text, pos: start}, nil
+}
+
+// Quoted strings are only case-insensitive for US-ASCII letters, so unlike
+// LexFold, "k" doesn't match the Kelvin sign. Each letter becomes a class
+// of its two cases, under an alias that names the token after the string.
+func asciiFold(text string, start int) *ruleExpr {
+	parts := []*ruleExpr{}
+	letters := false
+	for _, r := range text {
+		if r < utf8.RuneSelf && unicode.IsLetter(r) {
+			upper, lower := unicode.ToUpper(r), unicode.ToLower(r)
+			parts = append(parts, &ruleExpr{kind: ruleClass, ranges: [][2]rune{{upper, upper}, {lower, lower}}, pos: start})
+			letters = true
+		} else {
+			parts = append(parts, &ruleExpr{kind: ruleLiteral, text: string(r), pos: start})
+		}
+	}
+	if !letters {
+		return &ruleExpr{kind: ruleLiteral, text: text, pos: start}
+	}
+	and := &ruleExpr{kind: ruleAnd, children: parts, pos: start}
+	return &ruleExpr{kind: ruleAlias, text: text, children: []*ruleExpr{and}, pos: start}
+}
+
+// Reads %x41, %x41-5A, %x41.42.43, their %d and %b forms,
+// and the %s"..." and %i"..." strings of RFC 7405.
+func (self *abnfParser) numeric(start int) (*ruleExpr, error) {
+	self.pos++
+	if self.done() {
+		return nil, self.error(start, "unexpected end of grammar")
+	}
+	base := 0
+	switch self.peek() {
+	case 'x', 'X':
+		base = 16
+	case 'd', 'D':
+		base = 10
+	case 'b', 'B':
+		base = 2
+	case 's', 'S', 'i', 'I':
+		fold := self.peek() == 'i' || self.peek() == 'I'
+		self.pos++
+		if self.done() || self.peek() != '"' {
+			return nil, self.error(self.pos, "expected '\"'")
+		}
+		return self.quoted(start, fold)
+	default:
+		return nil, self.error(self.pos, "unknown numeric value %%%c", self.peek())
+	}
+	self.pos++
+
+	value := func() (rune, error) {
+		from := self.pos
+		for !self.done() && strings.ContainsRune("0123456789abcdefABCDEF", self.peek()) {
+			self.pos++
+		}
+		n, err := strconv.ParseUint(string(self.text[from:self.pos]), base, 32)
+		if err != nil || n > 0x10ffff {
+			return 0, self.error(from, "invalid numeric value")
+		}
+		return rune(n), nil
+	}
+
+	first, err := value()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !self.done() && self.peek() == '-':
+		self.pos++
+		last, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if last < first {
+			return nil, self.error(start, "invalid range")
+		}
+		return &ruleExpr{kind: ruleClass, ranges: [][2]rune{{first, last}}, pos: start}, nil
+	case !self.done() && self.peek() == '.':
+		text := []rune{first}
+		for !self.done() && self.peek() == '.' {
+			self.pos++
+			next, err := value()
+			if err != nil {
+				return nil, err
+			}
+			text = append(text, next)
+		}
+		return &ruleExpr{kind: ruleLiteral, text: string(text), pos: start}, nil
+	}
+	return &ruleExpr{kind: ruleLiteral, text: string(first), pos: start}, nil
+}
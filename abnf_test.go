@@ -0,0 +1,111 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestLoadABNF(t *testing.T) {
+	rules, err := LoadABNF(`
+; A request line, roughly as in RFC 7230.
+request-line   = method SP request-target SP http-version CRLF
+method         = "GET" / "POST"
+method         =/ %s"PUT" ; Only in upper case.
+request-target = 1*( ALPHA / DIGIT / "/" /
+                     "." )
+HTTP-version   = %s"HTTP" "/" DIGIT %x2E DIGIT
+`)
+	if err != nil {
+		t.Fatalf("LoadABNF fails: %s", err)
+	}
+
+	line := rules["request-line"]
+	if !line.Match("GET /index.html HTTP/1.1\r\n") {
+		t.Error("ABNF rules don't match a request line")
+	}
+	result := line.MustCompile("get /a HTTP/1.0\r\n")
+	if result.Tokens()[0].Name != "GET" || result.Tokens()[0].Value != "get" {
+		t.Errorf("ABNF strings don't match in any case: %v", result.Tokens()[0])
+	}
+	if !line.Match("PUT /a HTTP/1.0\r\n") || line.Match("put /a HTTP/1.0\r\n") {
+		t.Error("ABNF =/ or case-sensitive strings don't work")
+	}
+	if line.Match("GET /a http/1.0\r\n") {
+		t.Error("ABNF case-sensitive strings match in any case")
+	}
+	if _, ok := rules["HTTP-version"]; !ok {
+		t.Error("ABNF rules aren't kept under the name they're defined with")
+	}
+}
+
+func TestABNFRepetition(t *testing.T) {
+	rules, err := LoadABNF("exact = 2DIGIT\r\nrange = 2*3DIGIT\r\nmost = *2DIGIT \"x\"\r\noption = [\"-\"] DIGIT\r\nvalues = %d97.98 %x63-64\r\n")
+	if err != nil {
+		t.Fatalf("LoadABNF fails: %s", err)
+	}
+	if len(rules["exact"].Compile("1234")) != 1 || rules["exact"].Match("1") {
+		t.Error("ABNF nDIGIT repeats the wrong number of times")
+	}
+	if len(rules["range"].Compile("1234")) != 2 || rules["range"].Match("1") {
+		t.Error("ABNF n*mDIGIT repeats the wrong number of times")
+	}
+	if !rules["most"].Match("x") || !rules["most"].Match("12x") || rules["most"].Match("123x") {
+		t.Error("ABNF *mDIGIT repeats the wrong number of times")
+	}
+	if !rules["option"].Match("-1") || !rules["option"].Match("1") {
+		t.Error("ABNF options are not optional")
+	}
+	if !rules["values"].Match("abc") {
+		t.Error("ABNF numeric values don't match")
+	}
+}
+
+func TestCoreRules(t *testing.T) {
+	if !HEXDIG.Match("f") || !HEXDIG.Match("A") || HEXDIG.Match("g") {
+		t.Error("HEXDIG is wrong")
+	}
+	if !VCHAR.Match("~") || VCHAR.Match(" ") {
+		t.Error("VCHAR is wrong")
+	}
+	if !CRLF.Match("\r\n") || !WSP.Match("\t") || !LWSP.Match(" \r\n x") {
+		t.Error("The whitespace core rules are wrong")
+	}
+}
+
+func TestLoadABNFErrors(t *testing.T) {
+	errors := map[string]string{
+		"a = b":                `1:5: undefined rule "b"`,
+		"a = \"x\"\na =/ b":    `2:6: undefined rule "b"`,
+		"a =/ \"x\"":           `1:1: rule "a" is extended with '=/' before it is defined`,
+		"a = \"x\"\nA = \"y\"": `2:1: rule "A" is defined twice, use '=/' to add alternatives`,
+		"a = <prose>":          `1:5: prose values are not supported`,
+		"a = (\"x\"":           `1:9: expected ')'`,
+		"a = %x5A-41":          `1:5: invalid range`,
+		"a \"x\"":              `1:3: expected '=' or '=/' after rule name "a"`,
+	}
+	for grammar, expected := range errors {
+		_, err := LoadABNF(grammar)
+		if err == nil {
+			t.Errorf("LoadABNF accepts %q", grammar)
+		} else if err.Error() != expected {
+			t.Errorf("LoadABNF gives %q instead of %q", err, expected)
+		}
+	}
+}
+
+func TestABNFFold(t *testing.T) {
+	rules, err := LoadABNF("keyword = \"ok-1\"\r\n")
+	if err != nil {
+		t.Fatalf("LoadABNF fails: %s", err)
+	}
+	result := rules["keyword"].MustCompile("Ok-1")
+	if len(result.Tokens()) != 1 || result.Tokens()[0].Name != "ok-1" || result.Tokens()[0].Value != "Ok-1" {
+		t.Errorf("ABNF strings give %v", result.Tokens())
+	}
+	// U+212A, the Kelvin sign, folds to k, but not in US-ASCII.
+	if rules["keyword"].Match("o\u212a-1") {
+		t.Error("ABNF strings match in any case beyond US-ASCII")
+	}
+	if !OCTET.Match("\u00ff") || OCTET.Match("\xff") {
+		t.Error("OCTET does not match the characters up to U+00FF")
+	}
+}
@@ -2,8 +2,10 @@ package abstract
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"strings"
+	"unicode"
 )
 
 type Action int
@@ -18,13 +20,33 @@ const (
 	NMANY
 	FIRST
 	OPERATOR // Only used in Syntax Tree part.
+	CLASS
+	FOLD
+	PEEK
+	NOT
+	REPEAT
+	SEPBY
+	UNTIL
+	STRING
+	CAPTURE
+	BACKREF
+	PUSH
+	POP
+	SKIP
+	ATOMIC
+	KEYWORDS
 )
 
 type Token struct {
-	Name  string
-	Value string
+	Name    string
+	Value   string
+	raw     string // The exact text the token was lexed from.
+	has_raw bool
 }
 
+// The name given to tokens made by Garbage.
+const garbage = "abstract://garbage"
+
 type operator struct {
 	name  string
 	left  int
@@ -38,6 +60,7 @@ func Operator(name string, left int, right int) *operator {
 type Result struct {
 	tokens    []*Token
 	left_over string
+	captures  *binding // What Capture has bound on the way to this result.
 }
 
 func (r *Result) Tokens() []*Token {
@@ -47,8 +70,16 @@ func (r *Result) Tokens() []*Token {
 type Lexer struct {
 	token    string
 	action   Action
-	to       int // Only used for nmany
-	from     int // Only used for nmany
+	to       int        // Only used for nmany and repeat
+	from     int        // Only used for nmany, repeat and sepby
+	trailing bool       // Only used for sepby
+	delims   [2]string  // Only used for string: the quote and the escape
+	unescape Unescaper  // Only used for string
+	name     string     // Only used for capture, backref and push
+	mode     RepeatMode // Only used for repeat
+	class    class      // Only used for class
+	keywords *keywords  // Only used for keywords
+	longest  bool       // Only used for searching
 	children []*Lexer
 }
 
@@ -162,7 +193,7 @@ func (self *Lexer) Alias(str string) *Lexer {
 func (self *Lexer) Garbage() *Lexer {
 	b := base()
 	b.children = append(b.children, self)
-	b.token = "abstract://garbage"
+	b.token = garbage
 	return b
 }
 
@@ -170,12 +201,21 @@ func Garbage(l *Lexer) *Lexer {
 	return l.Garbage()
 }
 
-func singleResult(toks []*Token, left_over string) []*Result {
-	return []*Result{&Result{tokens: toks, left_over: left_over}}
+func singleResult(toks []*Token, left_over string, captures *binding) []*Result {
+	return []*Result{&Result{tokens: toks, left_over: left_over, captures: captures}}
 }
 
 func newToken(str string) *Token {
-	return &Token{str, str}
+	return &Token{Name: str, Value: str, raw: str, has_raw: true}
+}
+
+// The text a token stands for in the input. Tokens that weren't
+// made by a Lexer fall back on their value.
+func (self *Token) source() string {
+	if self.has_raw {
+		return self.raw
+	}
+	return self.Value
 }
 
 func (self *Token) String() string {
@@ -183,22 +223,69 @@ func (self *Token) String() string {
 }
 
 func (self *Lexer) Compile(str string) []*Result {
+	return self.compile(str, nil)
+}
+
+// Compiles str on a track where captures are bound.
+func (self *Lexer) compile(str string, captures *binding) []*Result {
+
+	if self.action == CLASS {
+		return self.compileClass(str, captures)
+	}
+	if self.action == FOLD {
+		return self.compileFold(str, captures)
+	}
+	if self.action == PEEK || self.action == NOT {
+		return self.compileLookahead(str, captures)
+	}
+	if self.action == REPEAT {
+		return self.compileRepeat(str, captures)
+	}
+	if self.action == SEPBY {
+		return self.compileSepBy(str, captures)
+	}
+	if self.action == UNTIL {
+		return self.compileUntil(str, captures)
+	}
+	if self.action == STRING {
+		return self.compileString(str, captures)
+	}
+	if self.action == CAPTURE {
+		return self.compileCapture(str, captures)
+	}
+	if self.action == BACKREF {
+		return self.compileBackref(str, captures)
+	}
+	if self.action == PUSH || self.action == POP {
+		return self.compileMode(str, captures)
+	}
+	if self.action == SKIP {
+		return self.compileSkip(str, captures)
+	}
+	if self.action == ATOMIC {
+		return self.children[0].compile(str, captures)
+	}
+	if self.action == KEYWORDS {
+		return self.compileKeywords(str, captures)
+	}
 
 	if len(self.children) == 0 {
 		s := string(append([]byte(str), 0))
-		if strings.HasPrefix(s, self.token) {
+		if hasRunePrefix(s, self.token) {
 			// Return one result that has this token and the rest of the string.
 			if self.token == string([]byte{0}) {
-				return singleResult([]*Token{newToken(self.token)}, str)
+				eof := newToken(self.token)
+				eof.raw = ""
+				return singleResult([]*Token{eof}, str, captures)
 			}
-			return singleResult([]*Token{newToken(self.token)}, str[len(self.token):])
+			return singleResult([]*Token{newToken(self.token)}, str[len(self.token):], captures)
 		}
 		return []*Result{}
 	}
 
 	// Otherwise there are children:
 
-	current_list := singleResult([]*Token{}, str)
+	current_list := singleResult([]*Token{}, str, captures)
 
 	xor_list := []*Result{}
 
@@ -213,21 +300,24 @@ NextChild:
 
 		for _, result := range current_list {
 
-			child_results := child.Compile(result.left_over)
+			child_results := child.compile(result.left_over, result.captures)
 			for _, res := range child_results {
 
 				var the_tokens []*Token
 				if self.token != "" {
 
 					value := ""
+					raw := ""
 
-					if self.token != "abstract://garbage" {
-						for _, tok := range res.tokens {
+					for _, tok := range res.tokens {
+						// Tokens that stand for no text, like push, add nothing.
+						if self.token != garbage && tok.source() != "" {
 							value = value + tok.Value
 						}
+						raw = raw + tok.source()
 					}
 
-					the_tokens = []*Token{&Token{self.token, value}}
+					the_tokens = []*Token{&Token{Name: self.token, Value: value, raw: raw, has_raw: true}}
 				} else {
 					the_tokens = res.tokens
 				}
@@ -239,7 +329,7 @@ NextChild:
 
 		switch self.action {
 		case OR:
-			output_list = append(output_list, &Result{[]*Token{}, str})
+			output_list = append(output_list, &Result{tokens: []*Token{}, left_over: str, captures: captures})
 		case AND:
 			if len(output_list) == 0 {
 				return []*Result{}
@@ -273,12 +363,11 @@ NextChild:
 			many_list = output_list // Basically save the last one.
 			current_list = output_list
 			goto ThisChild
-		// case FIRST:
-		// 	if len(output_list) == 0 {
-		// 		continue NextChild
-		// 	} else { // This isn't working?
-		// 		return output_list
-		// 	}
+		case FIRST:
+			if len(output_list) == 0 {
+				continue NextChild
+			}
+			return output_list
 		}
 		if self.action != XOR {
 			current_list = output_list
@@ -287,28 +376,39 @@ NextChild:
 	if self.action == XOR {
 		return xor_list
 	}
+	if self.action == FIRST {
+		return []*Result{} // None of the children matched.
+	}
 
 	return current_list
 }
 
 func (l *Lexer) MustCompile(str string) *Result {
+	result, err := l.compileAll(str)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
+
+// Like MustCompile, but returns an error instead of panicking.
+func (l *Lexer) compileAll(str string) (*Result, error) {
 	results := l.Compile(str)
 	switch len(results) {
 	case 0:
-		panic("Lexer did not compile.")
+		return nil, errors.New("Lexer did not compile.")
 	case 1:
 		if results[0].left_over != "" {
-			panic("Lexer did not compile well.")
+			return nil, errors.New("Lexer did not compile well.")
 		}
-		return results[0]
+		return results[0], nil
 	default:
 		for _, res := range results {
 			if res.left_over == "" {
-				return res
+				return res, nil
 			}
 		}
-		panic("Nondeterministic parse without a good solution!")
-		// return results[1]
+		return nil, errors.New("Nondeterministic parse without a good solution!")
 		// Not a very smart algorithm.
 	}
 }
@@ -354,19 +454,26 @@ func PrintResults(results []*Result) {
 //   PrintResults(results)
 // }
 
-var Digit *Lexer = OneOf(LexMultiple("0123456789")...)
-var Lower *Lexer = OneOf(LexMultiple("abcdefghijklmnopqrstuvwxyz")...)
-var Upper *Lexer = OneOf(LexMultiple("ABCDEFGHIJKLMNOPQRSTUVWXYZ")...)
-var Alpha *Lexer = OneOf(Upper, Lower)
-var Alphanumeric *Lexer = OneOf(Alpha, Digit)
+var Digit *Lexer = Range('0', '9')
+var Lower *Lexer = Range('a', 'z')
+var Upper *Lexer = Range('A', 'Z')
+var Alpha *Lexer = Union(Upper, Lower)
+var Alphanumeric *Lexer = Union(Alpha, Digit)
 var Eof *Lexer = Lex(string([]byte{0}))
-var Space = OneOf(Lex(" "), Lex("\n"), Lex("\t"))
+var Space = FromTable(unicode.White_Space)
 
 //// Abstract Syntax Trees.
 
 type Abstract struct {
 	Token    *Token
 	Children []*Abstract
+
+	// Garbage or skipped tokens kept around the token by Trivia.
+	Leading  []*Token
+	Trailing []*Token
+
+	infix   bool      // Set by Rule: the token sits between its two children.
+	closing *Abstract // Set by Between: the right delimiter.
 }
 
 func (self *Abstract) String() string {
@@ -479,6 +586,11 @@ func (self *Abstract) parentApply(lexer *Lexer) {
 	result := lexer.MustCompile(self.Token.Value)
 	other := AbstractFromResult(result)
 	self.Children = other.Children
+	if other.Source() == self.Token.source() {
+		// The children now hold the text.
+		self.Token.raw = ""
+		self.Token.has_raw = true
+	}
 	self.Token.Value = result.left_over
 }
 
@@ -518,9 +630,14 @@ func (self *Abstract) Between(left string, right string) {
 			right_child := abstract.Children[rightmost]
 
 			new_token := &Token{
-				Name:  left_child.Token.Name + right_child.Token.Name,
-				Value: left_child.Token.Value + right_child.Token.Value}
+				Name:    left_child.Token.Name + right_child.Token.Name,
+				Value:   left_child.Token.Value + right_child.Token.Value,
+				raw:     left_child.Token.source(),
+				has_raw: true}
 			new_child := AbstractFromToken(new_token)
+			new_child.Leading = left_child.Leading
+			new_child.Trailing = left_child.Trailing
+			new_child.closing = right_child
 			new_child.Children = make([]*Abstract, rightmost-leftmost-1)
 
 			copy(new_child.Children, abstract.Children[leftmost+1:rightmost])
@@ -577,6 +694,7 @@ func (self *Abstract) Rule(ops ...*operator) {
 				left.Children = alternative_children[i-left_number : i]
 				right.Children = alternative_children[i+1 : i+1+right_number]
 				child.Children = []*Abstract{left, right}
+				child.infix = true
 
 				abstract.Children = append(append(abstract.Children[:i-left_number], abstract.Children[i]), abstract.Children[i+1+right_number:]...)
 
@@ -84,6 +84,22 @@ func TestOneOf(t *testing.T) {
 	}
 }
 
+func TestFirstOf(t *testing.T) {
+	lexer := FirstOf(And(a, b), a, c)
+	results := lexer.Compile("abc")
+	if len(results) != 1 || results[0].left_over != "c" {
+		t.Error("FirstOf doesn't stop at the first child that matches")
+	}
+	results = lexer.Compile("ac")
+	if len(results) != 1 || results[0].left_over != "c" {
+		t.Error("FirstOf doesn't try the next child")
+	}
+	results = lexer.Compile("b")
+	if len(results) != 0 {
+		t.Error("FirstOf matches when none of its children do")
+	}
+}
+
 func TestMany(t *testing.T) {
 	lexer := Many(a)
 	results := lexer.Compile("baaaaaaa")
@@ -0,0 +1,64 @@
+package abstract
+
+import (
+	"strings"
+)
+
+//// Captures and Backreferences.
+
+// The text captured under a name on one track of a Compile. Bindings
+// are never changed, so tracks that split can share them.
+type binding struct {
+	name  string
+	value string
+	next  *binding
+}
+
+func (self *binding) lookup(name string) (string, bool) {
+	for b := self; b != nil; b = b.next {
+		if b.name == name {
+			return b.value, true
+		}
+	}
+	return "", false
+}
+
+// Capture matches lexer and remembers the text it matched under name, for
+// Backref to match again later on. Each result of a Compile keeps its own
+// captures, and capturing a name again replaces it from there on.
+func Capture(name string, lexer *Lexer) *Lexer {
+	b := base()
+	b.action = CAPTURE
+	b.name = name
+	b.children = append(b.children, lexer)
+	return b
+}
+
+// Backref matches the text last captured under name, as a single token.
+// It doesn't match if nothing was captured under name.
+//
+//	fence := Capture("fence", Munch(Lex("`")))
+//	code := And(fence, Until(Backref("fence")), Backref("fence"))
+func Backref(name string) *Lexer {
+	b := base()
+	b.action = BACKREF
+	b.name = name
+	return b
+}
+
+func (self *Lexer) compileCapture(str string, captures *binding) []*Result {
+	results := self.children[0].compile(str, captures)
+	for _, result := range results {
+		text := str[:len(str)-len(result.left_over)]
+		result.captures = &binding{self.name, text, result.captures}
+	}
+	return results
+}
+
+func (self *Lexer) compileBackref(str string, captures *binding) []*Result {
+	text, ok := captures.lookup(self.name)
+	if !ok || !strings.HasPrefix(str, text) {
+		return []*Result{}
+	}
+	return singleResult([]*Token{newToken(text)}, str[len(text):], captures)
+}
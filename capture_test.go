@@ -0,0 +1,57 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	// <<EOF ... EOF
+	word := Munch(Upper).Alias("word")
+	heredoc := And(Lex("<<"), Capture("end", word), Lex("\n"), Until(And(Lex("\n"), Backref("end"))), Lex("\n"), Backref("end"))
+	result := heredoc.MustCompile("<<END\nsome\ntext\nEND")
+	if result.Tokens()[3].Value != "some\ntext" {
+		t.Errorf("The heredoc gives %v", result.Tokens())
+	}
+	if heredoc.Match("<<END\ntext\nEOF") {
+		t.Error("Backref matches text that wasn't captured")
+	}
+
+	// r#"..."#, with as many #'s on both sides.
+	hashes := Capture("hashes", Maybe(Munch(Lex("#"))))
+	closing := And(Lex("\""), Backref("hashes"))
+	raw := And(Lex("r"), hashes, Lex("\""), Until(closing), closing)
+	if _, err := raw.compileAll(`r##"a "# b"##`); err != nil {
+		t.Error("The raw string does not match")
+	}
+	if raw.Match(`r#"a`) {
+		t.Error("The raw string matches without its end")
+	}
+	if Backref("nothing").Match("") {
+		t.Error("Backref matches a name that was never captured")
+	}
+}
+
+func TestCaptureTracks(t *testing.T) {
+	// Each length of "a"'s is its own track, with its own capture.
+	lexer := And(Capture("x", Many(a)), Lex("-"), Backref("x"))
+	if _, err := lexer.compileAll("aa-aa"); err != nil {
+		t.Error("Captures are mixed up between tracks")
+	}
+	if _, err := lexer.compileAll("aa-a"); err == nil {
+		t.Error("Backref matches a capture from another track")
+	}
+}
+
+func TestCaptureRules(t *testing.T) {
+	rules, err := LoadRules(`fence = <f: "` + "`" + `"+> (!<=f> .)* <=f> ;`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if _, err := rules["fence"].compileAll("``a`b``"); err != nil {
+		t.Error("<name: a> and <=name> don't load")
+	}
+	ebnf := rules["fence"].EBNF()
+	if ebnf != "main = <f: \"`\"+> (!<=f> .)* <=f> ;\n" {
+		t.Errorf("EBNF writes %s", ebnf)
+	}
+}
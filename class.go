@@ -0,0 +1,188 @@
+package abstract
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//// Character Classes.
+
+type runeRange struct {
+	lo rune
+	hi rune
+}
+
+// A set of characters, as sorted ranges that neither overlap nor touch.
+type class []runeRange
+
+func newClass(ranges ...runeRange) class {
+	sorted := make([]runeRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lo < sorted[j].lo })
+
+	out := class{}
+	for _, r := range sorted {
+		if len(out) > 0 && r.lo <= out[len(out)-1].hi+1 {
+			if r.hi > out[len(out)-1].hi {
+				out[len(out)-1].hi = r.hi
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func (self class) contains(r rune) bool {
+	i := sort.Search(len(self), func(i int) bool { return self[i].hi >= r })
+	return i < len(self) && self[i].lo <= r
+}
+
+func (self class) union(other class) class {
+	return newClass(append(append([]runeRange{}, self...), other...)...)
+}
+
+func (self class) negate() class {
+	out := class{}
+	next := rune(0)
+	for _, r := range self {
+		if r.lo > next {
+			out = append(out, runeRange{next, r.lo - 1})
+		}
+		next = r.hi + 1
+	}
+	if next <= unicode.MaxRune {
+		out = append(out, runeRange{next, unicode.MaxRune})
+	}
+	return out
+}
+
+func (self class) minus(other class) class {
+	return self.negate().union(other).negate()
+}
+
+func classLexer(c class) *Lexer {
+	b := base()
+	b.action = CLASS
+	b.class = c
+	return b
+}
+
+// Matches one character of the class.
+func (self *Lexer) compileClass(str string, captures *binding) []*Result {
+	r, size := utf8.DecodeRuneInString(str)
+	if size == 0 || (r == utf8.RuneError && size == 1) || !self.class.contains(r) {
+		return []*Result{}
+	}
+	return singleResult([]*Token{newToken(str[:size])}, str[size:], captures)
+}
+
+// The class a lexer matches, if it only ever matches a single character.
+func (self *Lexer) asClass() (class, bool) {
+	switch {
+	case self.action == CLASS:
+		return self.class, true
+	case len(self.children) == 0 && (self.action == NONE || self.action == FOLD):
+		r, size := utf8.DecodeRuneInString(self.token)
+		if size == 0 || size != len(self.token) || r == 0 {
+			return nil, false
+		}
+		if self.action == FOLD {
+			return foldClass(r), true
+		}
+		return newClass(runeRange{r, r}), true
+	case self.action == XOR && self.token == "":
+		out := class{}
+		for _, child := range self.children {
+			c, ok := child.asClass()
+			if !ok {
+				return nil, false
+			}
+			out = out.union(c)
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func mustClass(lexer *Lexer, function string) class {
+	c, ok := lexer.asClass()
+	if !ok {
+		panic(function + " only takes lexers that match a single character, like Range, NoneOf or Lex(\"a\").")
+	}
+	return c
+}
+
+// Range matches any one character from lo to hi, both included.
+func Range(lo rune, hi rune) *Lexer {
+	if hi < lo {
+		panic("Range requires lo <= hi.")
+	}
+	return classLexer(newClass(runeRange{lo, hi}))
+}
+
+// Any matches any one character.
+func Any() *Lexer {
+	return classLexer(newClass(runeRange{0, unicode.MaxRune}))
+}
+
+// NoneOf matches any one character that isn't in chars.
+func NoneOf(chars string) *Lexer {
+	ranges := []runeRange{}
+	for _, r := range chars {
+		ranges = append(ranges, runeRange{r, r})
+	}
+	return classLexer(newClass(ranges...).negate())
+}
+
+// Union matches any one character matched by one of the lexers. It takes
+// single characters, like Lex("a"), classes, and OneOf those, and checks
+// them all at once instead of trying each in turn.
+func Union(lexers ...*Lexer) *Lexer {
+	out := class{}
+	for _, lexer := range lexers {
+		out = out.union(mustClass(lexer, "Union"))
+	}
+	return classLexer(out)
+}
+
+// Except matches any one character matched by lexer but not by excluded.
+func Except(lexer *Lexer, excluded *Lexer) *Lexer {
+	return classLexer(mustClass(lexer, "Except").minus(mustClass(excluded, "Except")))
+}
+
+// Writes a class the way LoadRules reads it.
+func (self class) String() string {
+	if len(self) == 1 && self[0].lo == 0 && self[0].hi == unicode.MaxRune {
+		return "."
+	}
+	c := self
+	var out strings.Builder
+	out.WriteByte('[')
+	if len(c) > 0 && c[len(c)-1].hi == unicode.MaxRune {
+		out.WriteByte('^')
+		c = c.negate()
+	}
+	for _, r := range c {
+		out.WriteString(classChar(r.lo))
+		if r.hi > r.lo+1 {
+			out.WriteByte('-')
+		}
+		if r.hi > r.lo {
+			out.WriteString(classChar(r.hi))
+		}
+	}
+	out.WriteByte(']')
+	return out.String()
+}
+
+func classChar(r rune) string {
+	switch r {
+	case '\\', ']', '[', '-', '^':
+		return "\\" + string(r)
+	}
+	quoted := quoteLiteral(string(r))
+	return quoted[1 : len(quoted)-1]
+}
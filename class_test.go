@@ -0,0 +1,71 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestClasses(t *testing.T) {
+	hex := Union(Digit, Range('a', 'f'), Lex("x"), OneOfString("y", "z"))
+	for _, str := range []string{"0", "9", "a", "f", "x", "z"} {
+		if !hex.Match(str) {
+			t.Errorf("Union does not match %q", str)
+		}
+	}
+	if hex.Match("g") || hex.Match("") {
+		t.Error("Union matches too much")
+	}
+
+	if !Any().Match("é") || Any().Match("") {
+		t.Error("Any does not match exactly one character")
+	}
+	if NoneOf("\"\n").Match("\"") || !NoneOf("\"\n").Match("a") {
+		t.Error("NoneOf is wrong")
+	}
+	consonant := Except(Lower, OneOfString("a", "e", "i", "o", "u"))
+	if consonant.Match("e") || !consonant.Match("b") || consonant.Match("B") {
+		t.Error("Except is wrong")
+	}
+
+	result := Munch(Range('α', 'ω')).MustCompile("αβγ")
+	if len(result.Tokens()) != 3 || result.Tokens()[1].Value != "β" {
+		t.Errorf("Classes don't match whole characters: %v", result.Tokens())
+	}
+}
+
+func TestClassErrors(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Union takes lexers matching more than one character")
+		}
+	}()
+	Union(Lex("ab"))
+}
+
+func TestClassString(t *testing.T) {
+	classes := map[*Lexer]string{
+		Range('a', 'c'):                  "[a-c]",
+		Union(Lex("a"), Lex("b")):        "[ab]",
+		NoneOf("]\n"):                    "[^\\n\\]]",
+		Any():                            ".",
+		Union(Lex("-"), Range('0', '9')): "[\\-0-9]",
+	}
+	for class, expected := range classes {
+		if class.class.String() != expected {
+			t.Errorf("The class %s should be written %s", class.class, expected)
+		}
+	}
+
+	rules, err := LoadRules(`word = [^ \n]+ @word ; line = . ("x" | [a-c])* ;`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if _, err := rules["word"].compileAll("a b"); !rules["word"].Match("hé!") || err == nil {
+		t.Error("Negated classes don't load")
+	}
+	if !rules["line"].Match(" abx") || rules["line"].Match("") {
+		t.Error("The . class doesn't load")
+	}
+	if rules["word"].EBNF() != "word = [^\\n ]+ @word ;\n" {
+		t.Errorf("EBNF writes %s", rules["word"].EBNF())
+	}
+}
@@ -0,0 +1,147 @@
+package abstract
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+//// Delimited Text.
+
+// Until matches everything up to where terminator matches, which it leaves
+// for the next lexer, as a single token. It fails if terminator never
+// matches; use Until(OneOf(end, Eof)) to also stop at the end of the input.
+func Until(terminator *Lexer) *Lexer {
+	b := base()
+	b.action = UNTIL
+	b.children = append(b.children, terminator)
+	return b
+}
+
+func (self *Lexer) compileUntil(str string, captures *binding) []*Result {
+	for i := 0; i <= len(str); {
+		if len(self.children[0].compile(str[i:], captures)) > 0 {
+			return singleResult([]*Token{newToken(str[:i])}, str[i:], captures)
+		}
+		if i == len(str) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(str[i:])
+		i += size
+	}
+	return []*Result{}
+}
+
+// Delimited matches open, then as many bodies as it can before close
+// matches, then close. Delimited(Lex("/*"), Any(), Lex("*/")) matches a
+// block comment.
+func Delimited(open *Lexer, body *Lexer, close *Lexer) *Lexer {
+	return And(open, Maybe(Munch(And(Not(close), body))), close)
+}
+
+// Nested is Delimited with any text as the body, where the delimiters may
+// nest: Nested(Lex("/*"), Lex("*/")) matches "/* a /* b */ c */" whole.
+func Nested(open *Lexer, close *Lexer) *Lexer {
+	nested := And(open)
+	text := And(Not(open), Not(close), Any())
+	nested.children = append(nested.children, Maybe(Munch(OneOf(nested, text))), close)
+	return nested
+}
+
+// StringLiteral matches text between two quotes, where escape followed by
+// a character stands for that character; \n, \t, \r and \0 stand for a
+// newline, a tab, a carriage return and NUL. Its token is named "string"
+// and holds the unescaped text, without the quotes, which are kept in its
+// Source like Garbage. An empty escape turns escaping off, and Unescape
+// decodes the escapes of a particular language.
+//
+//	StringLiteral("\"", "\\").MustCompile(`"a\"b\n"`) // string: a"b and a newline
+func StringLiteral(quote string, escape string) *Lexer {
+	if quote == "" {
+		panic("StringLiteral requires a quote.")
+	}
+	b := base()
+	b.action = STRING
+	b.token = "string"
+	b.delims = [2]string{quote, escape}
+	return b
+}
+
+// An Unescaper decodes an escape, given the text right after the escape
+// string. It returns what the escape stands for and how many bytes of text
+// follow the escape string in it, or false if it isn't a valid escape.
+type Unescaper func(text string) (value string, size int, ok bool)
+
+// Unescape returns a copy of a StringLiteral that decodes its escapes
+// with unescape. An invalid escape keeps the string from matching.
+func (self *Lexer) Unescape(unescape Unescaper) *Lexer {
+	if self.action != STRING {
+		panic("Unescape only applies to StringLiteral.")
+	}
+	lexer := *self
+	lexer.unescape = unescape
+	return &lexer
+}
+
+var unescapes = map[rune]string{'n': "\n", 't': "\t", 'r': "\r", '0': "\x00"}
+
+// The escapes StringLiteral knows by default.
+func unescapeSimple(text string) (string, int, bool) {
+	r, size := utf8.DecodeRuneInString(text)
+	if size == 0 {
+		return "", 0, false
+	}
+	if unescaped, ok := unescapes[r]; ok {
+		return unescaped, size, true
+	}
+	return text[:size], size, true
+}
+
+func (self *Lexer) compileString(str string, captures *binding) []*Result {
+	quote, escape := self.delims[0], self.delims[1]
+	if !strings.HasPrefix(str, quote) {
+		return []*Result{}
+	}
+	unescape := self.unescape
+	if unescape == nil {
+		unescape = unescapeSimple
+	}
+	var value strings.Builder
+	for i := len(quote); i < len(str); {
+		rest := str[i:]
+		switch {
+		case escape != "" && strings.HasPrefix(rest, escape):
+			unescaped, size, ok := unescape(rest[len(escape):])
+			if !ok {
+				return []*Result{}
+			}
+			value.WriteString(unescaped)
+			i += len(escape) + size
+		case strings.HasPrefix(rest, quote):
+			i += len(quote)
+			tok := &Token{Name: self.token, Value: value.String(), raw: str[:i], has_raw: true}
+			return singleResult([]*Token{tok}, str[i:], captures)
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			value.WriteString(rest[:size])
+			i += size
+		}
+	}
+	return []*Result{} // Unterminated.
+}
+
+// The same strings, written with lookaheads and without the unescaping.
+func (self *Lexer) stringExpansion() *Lexer {
+	quote := Lex(self.delims[0])
+	char := And(Not(quote), Any())
+	if self.delims[1] != "" {
+		escape := Lex(self.delims[1])
+		char = OneOf(And(escape.Garbage(), Any()), And(Not(quote), Not(escape), Any()))
+	}
+	return And(quote.Garbage(), Maybe(Munch(char)), quote.Garbage())
+}
+
+// The same text, written with lookaheads.
+func (self *Lexer) untilExpansion() *Lexer {
+	terminator := self.children[0]
+	return And(Maybe(Munch(And(Not(terminator), Any()))), Peek(terminator))
+}
@@ -0,0 +1,78 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUntil(t *testing.T) {
+	comment := And(Lex("//"), Until(OneOf(Lex("\n"), Eof)))
+	result := comment.Compile("// note\nx")[0]
+	if result.Tokens()[1].Value != " note" || result.left_over != "\nx" {
+		t.Errorf("Until gives %v, leaving %q", result.Tokens(), result.left_over)
+	}
+	if _, err := comment.compileAll("// last"); err != nil {
+		t.Error("Until does not stop at the end of the input")
+	}
+	if Until(Lex("*/")).Match("never closed") {
+		t.Error("Until matches without its terminator")
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	comment := Delimited(Lex("/*"), Any(), Lex("*/")).Alias("comment")
+	result := comment.Compile("/* a * b */ c")[0]
+	if result.Tokens()[0].Value != "/* a * b */" {
+		t.Errorf("Delimited gives %v", result.Tokens())
+	}
+
+	nested := Nested(Lex("/*"), Lex("*/")).Alias("comment")
+	result = nested.Compile("/* a /* b */ c */ d")[0]
+	if result.Tokens()[0].Value != "/* a /* b */ c */" {
+		t.Errorf("Nested gives %v", result.Tokens())
+	}
+	if nested.Match("/* a /* b */") {
+		t.Error("Nested matches unbalanced delimiters")
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	str := StringLiteral("\"", "\\")
+	result := str.Compile(`"a\"b\n" + 1`)[0]
+	tok := result.Tokens()[0]
+	if tok.Name != "string" || tok.Value != "a\"b\n" || tok.source() != `"a\"b\n"` {
+		t.Errorf("StringLiteral gives %v from %q", tok, tok.source())
+	}
+	if result.left_over != " + 1" {
+		t.Errorf("StringLiteral leaves %q", result.left_over)
+	}
+	if str.Match(`"open`) || str.Match(`"open\"`) {
+		t.Error("StringLiteral matches unterminated strings")
+	}
+	raw := StringLiteral("'''", "")
+	if raw.MustCompile(`'''a\n'b'''`).Tokens()[0].Value != `a\n'b` {
+		t.Error("StringLiteral without an escape unescapes")
+	}
+
+	rules, err := LoadRules(str.EBNF())
+	if err != nil {
+		t.Fatalf("LoadRules can't read %s: %s", str.EBNF(), err)
+	}
+	if !rules["main"].Match(`"a\"b"`) || rules["main"].Match(`"a\"`) {
+		t.Errorf("The EBNF of StringLiteral matches other strings: %s", str.EBNF())
+	}
+}
+
+func TestNestedEBNF(t *testing.T) {
+	// The rule refers to itself, which LoadRules doesn't read back.
+	nested := Nested(Lex("("), Lex(")"))
+	if !strings.HasPrefix(nested.EBNF(), "main = \"(\" (main |") {
+		t.Errorf("EBNF does not refer to the rule itself: %s", nested.EBNF())
+	}
+	if _, err := LoadRules(nested.EBNF()); err == nil || !strings.Contains(err.Error(), `rule "main" refers to itself`) {
+		t.Errorf("LoadRules gives %v for a rule that refers to itself", err)
+	}
+	if len(nested.Diagrams()) != 1 {
+		t.Error("Nested does not link its diagram to itself")
+	}
+}
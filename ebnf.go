@@ -0,0 +1,332 @@
+package abstract
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+//// Writing Lexers as Text Grammars.
+
+// The predefined lexers keep their names when written out.
+func builtinNames() map[*Lexer]string {
+	return map[*Lexer]string{
+		Digit:        "digit",
+		Lower:        "lower",
+		Upper:        "upper",
+		Alpha:        "alpha",
+		Alphanumeric: "alphanumeric",
+		Space:        "space",
+		Letter:       "letter",
+		Number:       "number",
+		Punct:        "punct",
+		Eof:          "eof",
+		ALPHA:        "ALPHA",
+		BIT:          "BIT",
+		CHAR:         "CHAR",
+		CR:           "CR",
+		CRLF:         "CRLF",
+		CTL:          "CTL",
+		DIGIT:        "DIGIT",
+		DQUOTE:       "DQUOTE",
+		HEXDIG:       "HEXDIG",
+		HTAB:         "HTAB",
+		LF:           "LF",
+		LWSP:         "LWSP",
+		OCTET:        "OCTET",
+		SP:           "SP",
+		VCHAR:        "VCHAR",
+		WSP:          "WSP",
+	}
+}
+
+// EBNF describes the lexer in the text grammar format read by LoadRules,
+// with the exception WriteGrammar explains.
+func (self *Lexer) EBNF() string {
+	var out strings.Builder
+	self.WriteGrammar(&out)
+	return out.String()
+}
+
+// WriteGrammar writes the lexer in the text grammar format read by LoadRules.
+// The lexer itself becomes the first rule, named main unless it is an alias.
+// Aliases become rules of their own, and so do lexers that are used in
+// several places, like Digit, rather than being written out every time.
+//
+// A lexer that contains itself, like Nested, gives a rule that refers to
+// itself. That describes it correctly, but LoadRules can't read it back,
+// since it rejects such rules.
+func (self *Lexer) WriteGrammar(w io.Writer) error {
+	writer := newGrammarWriter(self)
+	for i := 0; i < len(writer.order); i++ {
+		lexer := writer.order[i]
+		body := writer.body(lexer)
+		if lexer.action == KEYWORDS {
+			body += " ; " + keywordsComment(lexer.keywords)
+		} else {
+			body += " ;"
+		}
+		if _, err := fmt.Fprintf(w, "%s = %s\n", writer.names[lexer], body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type grammarWriter struct {
+	names map[*Lexer]string
+	taken map[string]bool
+	uses  map[*Lexer]int
+	order []*Lexer // The rules to write, found as the previous ones are written.
+
+	anonymous int
+}
+
+// A writer whose first rule is root, named main unless it is an alias.
+func newGrammarWriter(root *Lexer) *grammarWriter {
+	writer := &grammarWriter{
+		names: map[*Lexer]string{},
+		taken: map[string]bool{},
+		uses:  map[*Lexer]int{},
+	}
+	writer.count(root)
+
+	name := "main"
+	if root.isAlias() {
+		name = root.token
+	}
+	writer.name(root, name)
+	writer.order = append(writer.order, root)
+	return writer
+}
+
+func (self *Lexer) isAlias() bool {
+	return self.token != "" && self.token != garbage && len(self.children) > 0
+}
+
+func (self *grammarWriter) count(lexer *Lexer) {
+	self.uses[lexer]++
+	if self.uses[lexer] > 1 {
+		return
+	}
+	for _, child := range lexer.children {
+		self.count(child)
+	}
+}
+
+func (self *grammarWriter) name(lexer *Lexer, name string) string {
+	unique := name
+	for i := 2; self.taken[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	self.taken[unique] = true
+	self.names[lexer] = unique
+	return unique
+}
+
+// Returns the name of the rule for a lexer, if it should have one.
+func (self *grammarWriter) rule(lexer *Lexer) (string, bool) {
+	if name, ok := self.names[lexer]; ok {
+		return name, true
+	}
+	name, ok := builtinNames()[lexer]
+	switch {
+	case ok:
+	case lexer.isAlias():
+		name = lexer.token
+	case lexer.action == KEYWORDS:
+		name = "keywords" // A rule of its own, to have room for the words.
+	case self.uses[lexer] > 1 && len(lexer.children) > 0:
+		self.anonymous++
+		name = fmt.Sprintf("rule%d", self.anonymous)
+	default:
+		return "", false
+	}
+	self.order = append(self.order, lexer)
+	return self.name(lexer, name), true
+}
+
+func (self *grammarWriter) body(lexer *Lexer) string {
+	if lexer.isAlias() {
+		return self.expr(lexer.children[0], precSequence) + " @" + lexer.token
+	}
+	text, _ := self.inline(lexer)
+	return text
+}
+
+// Precedence levels, from loosest to tightest.
+const (
+	precChoice = iota
+	precSequence
+	precPrefix
+	precPostfix
+)
+
+// Writes the lexer as an expression that binds at least as tight as prec.
+func (self *grammarWriter) expr(lexer *Lexer, prec int) string {
+	if name, ok := self.rule(lexer); ok {
+		return name
+	}
+	text, own := self.inline(lexer)
+	if own < prec {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (self *grammarWriter) inline(lexer *Lexer) (string, int) {
+	if lexer.action == CLASS {
+		return lexer.class.String(), precPostfix
+	}
+	if lexer.action == FOLD {
+		return quoteLiteral(lexer.token) + "i", precPostfix
+	}
+	if lexer.action == STRING {
+		return self.inline(lexer.stringExpansion())
+	}
+	if lexer.action == UNTIL {
+		return self.inline(lexer.untilExpansion())
+	}
+	if lexer.action == BACKREF {
+		return "<=" + lexer.name + ">", precPostfix
+	}
+	if lexer.action == CAPTURE {
+		return "<" + lexer.name + ": " + self.expr(lexer.children[0], precChoice) + ">", precPostfix
+	}
+	if len(lexer.children) == 0 {
+		return quoteLiteral(lexer.token), precPostfix
+	}
+
+	if lexer.action == SEPBY {
+		return self.inline(lexer.sepByExpansion())
+	}
+	if lexer.action == SKIP {
+		skipped := "~" + self.expr(lexer.children[0], precPostfix) + "*"
+		if len(lexer.children) == 1 {
+			return skipped, precPrefix
+		}
+		return skipped + " " + self.expr(lexer.children[1], precPrefix), precSequence
+	}
+	child := lexer.children[0]
+	if lexer.token == garbage {
+		return "~" + self.expr(child, precPrefix), precPrefix
+	}
+
+	switch lexer.action {
+	case PEEK:
+		return "&" + self.expr(child, precPrefix), precPrefix
+	case NOT:
+		return "!" + self.expr(child, precPrefix), precPrefix
+	case AND:
+		return self.sequence(lexer.children), precSequence
+	case XOR:
+		return self.join(lexer.children, " | ", precSequence), precChoice
+	case FIRST:
+		return self.join(lexer.children, " / ", precSequence), precChoice
+	case MUNCH:
+		return self.expr(child, precPostfix) + "+", precPostfix
+	case MANY:
+		return self.expr(child, precPostfix) + "{1,}?", precPostfix
+	case NMANY:
+		return self.inline(lexer.nmanyExpansion())
+	case REPEAT:
+		return self.expr(child, precPostfix) + repeatBounds(lexer), precPostfix
+	case OR:
+		if _, named := self.rule(child); !named && child.action == MUNCH && child.token == "" {
+			return self.expr(child.children[0], precPostfix) + "*", precPostfix
+		}
+		return self.expr(child, precPostfix) + "?", precPostfix
+	}
+	return self.join(lexer.children, " ", precPrefix), precSequence
+}
+
+// Writes the {m,n} of a Repeat, with its mode.
+func repeatBounds(lexer *Lexer) string {
+	// LoadRules reads a* and a+ as these.
+	if lexer.mode == POSSESSIVE && lexer.to == UNBOUNDED && lexer.from == 0 {
+		return "*"
+	}
+	if lexer.mode == POSSESSIVE && lexer.to == UNBOUNDED && lexer.from == 1 {
+		return "+"
+	}
+	bounds := fmt.Sprintf("{%d,%d}", lexer.from, lexer.to)
+	if lexer.to == UNBOUNDED {
+		bounds = fmt.Sprintf("{%d,}", lexer.from)
+	} else if lexer.to == lexer.from {
+		bounds = fmt.Sprintf("{%d}", lexer.from)
+	}
+	switch lexer.mode {
+	case LAZY:
+		return bounds + "?"
+	case POSSESSIVE:
+		return bounds + "+"
+	}
+	return bounds
+}
+
+func (self *grammarWriter) join(lexers []*Lexer, separator string, prec int) string {
+	parts := make([]string, len(lexers))
+	for i, lexer := range lexers {
+		parts[i] = self.expr(lexer, prec)
+	}
+	return strings.Join(parts, separator)
+}
+
+// Like join, but the sequences WithSkip makes go without parentheses.
+func (self *grammarWriter) sequence(lexers []*Lexer) string {
+	parts := make([]string, len(lexers))
+	for i, lexer := range lexers {
+		if _, named := self.rule(lexer); !named && lexer.action == SKIP {
+			parts[i], _ = self.inline(lexer)
+		} else {
+			parts[i] = self.expr(lexer, precPrefix)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// The words of a Keywords lexer, which the grammar format has no way to
+// say, as a comment after its rule.
+func keywordsComment(keywords *keywords) string {
+	words := []string{}
+	keywords.words.walk(func(word string) {
+		words = append(words, quoteLiteral(word))
+	})
+	sort.Strings(words)
+	comment := "# or a keyword: " + strings.Join(words, " ")
+	if keywords.fold {
+		comment += ", in any case"
+	}
+	if keywords.contextual {
+		comment += ", where allowed"
+	}
+	return comment
+}
+
+// Quotes a literal the way LoadRules reads it.
+func quoteLiteral(str string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range str {
+		switch {
+		case r == '"' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == 0:
+			out.WriteString(`\0`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&out, `\x%02x`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
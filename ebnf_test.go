@@ -0,0 +1,64 @@
+package abstract
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEBNF(t *testing.T) {
+	number := And(Munch(Digit), Maybe(And(Lex("."), Munch(Digit)))).Alias("number")
+	spaces := Maybe(Munch(Lex(" "))).Garbage()
+	lexer := And(number, Many(And(spaces, OneOfString("+", "-"), spaces, number)), Eof)
+
+	expected := `main = number (rule1 ("+" | "-") rule1 number){1,}? eof ;
+number = digit+ ("." digit+)? @number ;
+rule1 = ~" "* ;
+eof = "\0" ;
+digit = [0-9] ;
+`
+	if lexer.EBNF() != expected {
+		t.Errorf("EBNF gives:\n%s", lexer.EBNF())
+	}
+
+	rules, err := LoadRules(lexer.EBNF())
+	if err != nil {
+		t.Fatalf("LoadRules can't read what EBNF writes: %s", err)
+	}
+	for _, str := range []string{"1 + 2.5", "1+2-3", "1 +", "1 ++ 2", "1.2.3"} {
+		if rules["main"].Match(str) != lexer.Match(str) {
+			t.Errorf("The loaded grammar doesn't match %q like the original", str)
+		}
+	}
+}
+
+func TestEBNFNames(t *testing.T) {
+	// Aliases sharing a name are told apart, but keep their alias.
+	lexer := OneOf(Lex("a").Alias("x"), Lex("b").Alias("x"), NMany(Lex("c"), 2, 4), FirstOf(Lex("d"), Lex("\"\n")))
+	expected := `main = x | x_2 | &"c"{2} "c"{1,4}? | ("d" / "\"\n") ;
+x = "a" @x ;
+x_2 = "b" @x ;
+`
+	if lexer.EBNF() != expected {
+		t.Errorf("EBNF gives:\n%s", lexer.EBNF())
+	}
+
+	if Lex("a").Alias("a").EBNF() != "a = \"a\" @a ;\n" {
+		t.Errorf("EBNF names an aliased lexer after its alias: %s", Lex("a").Alias("a").EBNF())
+	}
+}
+
+func TestEBNFNMany(t *testing.T) {
+	// NMany counts from 1, but only where there are enough to reach its min.
+	for _, lexer := range []*Lexer{NMany(Lex("c"), 3), NMany(Lex("c"), 2, 4), NMany(Lex("c"), 1, 2), NMany(Lex("c"), 3, 2)} {
+		rules, err := LoadRules(lexer.EBNF())
+		if err != nil {
+			t.Fatalf("LoadRules can't read %s: %s", lexer.EBNF(), err)
+		}
+		for _, str := range []string{"", "c", "cc", "ccc", "ccccc"} {
+			want, got := consumed(lexer.Compile(str), str), consumed(rules["main"].Compile(str), str)
+			if fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Errorf("%s gives %v on %q instead of %v", lexer.EBNF(), got, str, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package abstract
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"strings"
+)
+
+//// Comparing Abstract Syntax Trees.
+
+type Change int
+
+const (
+	ADDED Change = iota
+	REMOVED
+	CHANGED
+)
+
+func (self Change) String() string {
+	switch self {
+	case ADDED:
+		return "added"
+	case REMOVED:
+		return "removed"
+	case CHANGED:
+		return "changed"
+	}
+	return fmt.Sprintf("Change(%d)", int(self))
+}
+
+// A Difference between two trees. Path holds the child indices leading
+// from the root to the node through the old tree, except that the last
+// index of an ADDED node is its position among the new children.
+type Difference struct {
+	Path   []int
+	Change Change
+	Old    *Abstract // nil when ADDED
+	New    *Abstract // nil when REMOVED
+}
+
+func (self *Difference) String() string {
+	var out strings.Builder
+	for _, i := range self.Path {
+		fmt.Fprintf(&out, "/%d", i)
+	}
+	if len(self.Path) == 0 {
+		out.WriteString("/")
+	}
+	out.WriteString(": ")
+	out.WriteString(self.Change.String())
+	switch self.Change {
+	case ADDED:
+		fmt.Fprintf(&out, " %s", self.New)
+	case REMOVED:
+		fmt.Fprintf(&out, " %s", self.Old)
+	case CHANGED:
+		fmt.Fprintf(&out, " %s -> %s", tokenString(self.Old.Token), tokenString(self.New.Token))
+	}
+	return out.String()
+}
+
+func tokenString(tok *Token) string {
+	if tok == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%q:%q", tok.Name, tok.Value)
+}
+
+func sameToken(a, b *Token) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.Value == b.Value
+}
+
+// Equal reports whether both trees have the same shape and the same
+// token names and values. A nil token is only equal to a nil token.
+func (self *Abstract) Equal(other *Abstract) bool {
+	if self == nil || other == nil {
+		return self == other
+	}
+	if !sameToken(self.Token, other.Token) || len(self.Children) != len(other.Children) {
+		return false
+	}
+	for i, child := range self.Children {
+		if !child.Equal(other.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a structural hash that is stable across runs:
+// trees that are Equal always hash the same.
+func (self *Abstract) Hash() uint64 {
+	h := fnv.New64a()
+	self.hashInto(h)
+	return h.Sum64()
+}
+
+func (self *Abstract) hashInto(h hash.Hash64) {
+	if self.Token == nil {
+		h.Write([]byte{0})
+	} else {
+		// Lengths keep ("ab", "c") apart from ("a", "bc").
+		fmt.Fprintf(h, "\x01%d:%s%d:%s", len(self.Token.Name), self.Token.Name, len(self.Token.Value), self.Token.Value)
+	}
+	fmt.Fprintf(h, "[%d", len(self.Children))
+	for _, child := range self.Children {
+		child.hashInto(h)
+	}
+	h.Write([]byte{']'})
+}
+
+// Diff lists the differences between an old and a new tree. Children are
+// aligned by their longest common subsequence, so inserting one node
+// reports a single ADDED difference instead of changing every sibling after it.
+// A nil tree is empty, so a nil old tree gives one ADDED difference at the root.
+func Diff(old, new *Abstract) []*Difference {
+	switch {
+	case old == nil && new == nil:
+		return []*Difference{}
+	case old == nil:
+		return []*Difference{&Difference{Path: []int{}, Change: ADDED, New: new}}
+	case new == nil:
+		return []*Difference{&Difference{Path: []int{}, Change: REMOVED, Old: old}}
+	}
+	return diffNode([]int{}, old, new, []*Difference{})
+}
+
+func diffNode(path []int, old, new *Abstract, diffs []*Difference) []*Difference {
+	if !sameToken(old.Token, new.Token) {
+		diffs = append(diffs, &Difference{Path: path, Change: CHANGED, Old: old, New: new})
+	}
+	return diffChildren(path, old.Children, new.Children, diffs)
+}
+
+func diffChildren(path []int, old, new []*Abstract, diffs []*Difference) []*Difference {
+	old_hashes := make([]uint64, len(old))
+	for i, child := range old {
+		old_hashes[i] = child.Hash()
+	}
+	new_hashes := make([]uint64, len(new))
+	for i, child := range new {
+		new_hashes[i] = child.Hash()
+	}
+
+	// lengths[i][j] is the common subsequence length of old[i:] and new[j:].
+	lengths := make([][]int, len(old)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old_hashes[i] == new_hashes[j] && old[i].Equal(new[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	removed := []int{}
+	added := []int{}
+
+	// Unmatched runs between two common children are paired up as changes,
+	// whatever is left over is reported as removed or added.
+	flush := func() {
+		k := 0
+		for ; k < len(removed) && k < len(added); k++ {
+			diffs = diffNode(childPath(path, removed[k]), old[removed[k]], new[added[k]], diffs)
+		}
+		for _, r := range removed[k:] {
+			diffs = append(diffs, &Difference{Path: childPath(path, r), Change: REMOVED, Old: old[r]})
+		}
+		for _, a := range added[k:] {
+			diffs = append(diffs, &Difference{Path: childPath(path, a), Change: ADDED, New: new[a]})
+		}
+		removed = removed[:0]
+		added = added[:0]
+	}
+
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old_hashes[i] == new_hashes[j] && old[i].Equal(new[j]):
+			flush()
+			i++
+			j++
+		case j == len(new) || (i < len(old) && lengths[i+1][j] >= lengths[i][j+1]):
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+	flush()
+	return diffs
+}
+
+func childPath(path []int, i int) []int {
+	out := make([]int, len(path)+1)
+	copy(out, path)
+	out[len(path)] = i
+	return out
+}
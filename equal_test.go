@@ -0,0 +1,81 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func arithmeticTree(str string) *Abstract {
+	operator := OneOf(Lex("+"), Lex("-"), Lex("*"))
+	lexer := And(Digit, Maybe(Many(And(operator, Digit))))
+	tree := AbstractFromResult(lexer.MustCompile(str))
+	tree.Operator("*", 1, 1)
+	tree.Rule(Operator("+", 1, 1), Operator("-", 1, 1))
+	return tree
+}
+
+func TestEqual(t *testing.T) {
+	if !arithmeticTree("1+2*3").Equal(arithmeticTree("1+2*3")) {
+		t.Error("Equal trees are not Equal")
+	}
+	if arithmeticTree("1+2*3").Equal(arithmeticTree("1*2+3")) {
+		t.Error("Trees of a different shape are Equal")
+	}
+	if arithmeticTree("1+2*3").Equal(arithmeticTree("1+2*4")) {
+		t.Error("Trees with a different value are Equal")
+	}
+
+	named := AbstractFromToken(&Token{Name: "ab", Value: "c"})
+	valued := AbstractFromToken(&Token{Name: "a", Value: "bc"})
+	if named.Equal(valued) || named.Hash() == valued.Hash() {
+		t.Error("Name and value are not kept apart")
+	}
+	if AbstractWithName("").Equal(&Abstract{}) {
+		t.Error("An empty token is Equal to a nil token")
+	}
+}
+
+func TestHash(t *testing.T) {
+	if arithmeticTree("1+2*3").Hash() != arithmeticTree("1+2*3").Hash() {
+		t.Error("Equal trees hash differently")
+	}
+	if arithmeticTree("1+2*3").Hash() == arithmeticTree("1*2+3").Hash() {
+		t.Error("Different trees hash the same")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := AbstractParent(And(a, b, c).MustCompile("abc").Tokens())
+	if diffs := Diff(old, old); len(diffs) != 0 {
+		t.Errorf("Diff of a tree with itself gives %v", diffs)
+	}
+
+	// Inserting a node shouldn't change the nodes after it.
+	new := AbstractParent(And(a, c, b, c).MustCompile("acbc").Tokens())
+	diffs := Diff(old, new)
+	if len(diffs) != 1 || diffs[0].Change != ADDED || diffs[0].String() != "/1: added c:c[]" {
+		t.Errorf("Diff does not report a single addition: %v", diffs)
+	}
+
+	diffs = Diff(new, old)
+	if len(diffs) != 1 || diffs[0].Change != REMOVED || diffs[0].Path[0] != 1 {
+		t.Errorf("Diff does not report a single removal: %v", diffs)
+	}
+
+	// A nil tree is an empty parse.
+	diffs = Diff(nil, old)
+	if len(diffs) != 1 || diffs[0].Change != ADDED || diffs[0].String() != "/: added "+old.String() {
+		t.Errorf("Diff does not report a nil old tree: %v", diffs)
+	}
+	diffs = Diff(old, nil)
+	if len(diffs) != 1 || diffs[0].Change != REMOVED || len(diffs[0].Path) != 0 {
+		t.Errorf("Diff does not report a nil new tree: %v", diffs)
+	}
+	if diffs := Diff(nil, nil); len(diffs) != 0 {
+		t.Errorf("Diff of two nil trees gives %v", diffs)
+	}
+
+	diffs = Diff(arithmeticTree("1+2*3"), arithmeticTree("1+2*4"))
+	if len(diffs) != 1 || diffs[0].Change != CHANGED || diffs[0].String() != `/0/1/0/1/0: changed "3":"3" -> "4":"4"` {
+		t.Errorf("Diff does not find the changed leaf: %v", diffs)
+	}
+}
@@ -0,0 +1,64 @@
+package abstract
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+//// Case-Insensitive Literals.
+
+// LexFold matches str in any case, using Unicode case folding. Its token
+// is named str, and holds the text as it was typed.
+func LexFold(str string) *Lexer {
+	b := base()
+	b.action = FOLD
+	b.token = str
+	return b
+}
+
+// OneOfStringFold is OneOfString, matching each string in any case.
+func OneOfStringFold(strs ...string) *Lexer {
+	out := make([]*Lexer, 0)
+	for _, s := range strs {
+		out = append(out, LexFold(s))
+	}
+	return OneOf(out...)
+}
+
+// Whether a and b are the same character under simple case folding.
+func equalFold(a rune, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+// All the characters that fold to r.
+func foldClass(r rune) class {
+	ranges := []runeRange{{r, r}}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		ranges = append(ranges, runeRange{f, f})
+	}
+	return newClass(ranges...)
+}
+
+// Matches the token character by character, since folding can change
+// how many bytes a character takes.
+func (self *Lexer) compileFold(str string, captures *binding) []*Result {
+	i := 0
+	for _, want := range self.token {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if size == 0 || (r == utf8.RuneError && size == 1) || !equalFold(want, r) {
+			return []*Result{}
+		}
+		i += size
+	}
+	tok := newToken(str[:i])
+	tok.Name = self.token
+	return singleResult([]*Token{tok}, str[i:], captures)
+}
@@ -0,0 +1,42 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestLexFold(t *testing.T) {
+	keyword := OneOfStringFold("select", "from")
+	result := keyword.MustCompile("SeLeCt")
+	if result.Tokens()[0].Name != "select" || result.Tokens()[0].Value != "SeLeCt" {
+		t.Errorf("LexFold gives %v", result.Tokens()[0])
+	}
+	if keyword.Match("selec") || !keyword.Match("FROM") {
+		t.Error("OneOfStringFold is wrong")
+	}
+
+	// The Kelvin sign folds to k, but takes three bytes.
+	result = And(LexFold("ok"), Lex("!")).MustCompile("OK!")
+	if result.Tokens()[0].Value != "OK" || result.Tokens()[1].Value != "!" {
+		t.Errorf("LexFold does not fold whole characters: %v", result.Tokens())
+	}
+	if !LexFold("straße").Match("STRAẞE") {
+		t.Error("LexFold does not fold non-ASCII characters")
+	}
+
+	if !Union(LexFold("x"), Digit).Match("X") {
+		t.Error("Union does not take single folded characters")
+	}
+}
+
+func TestFoldRules(t *testing.T) {
+	rules, err := LoadRules(`main = "select"i " " id ; id = "i" alpha* ;`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if !rules["main"].Match("SELECT it") || rules["main"].Match("SELECT It") {
+		t.Error("\"...\"i literals don't load")
+	}
+	if LexFold("select").EBNF() != "main = \"select\"i ;\n" {
+		t.Errorf("EBNF writes %s", LexFold("select").EBNF())
+	}
+}
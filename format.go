@@ -0,0 +1,209 @@
+package abstract
+
+import (
+	"bytes"
+	"strings"
+)
+
+//// Printing Abstract Syntax Trees.
+
+// Spacing is the text written before and after a token.
+type Spacing struct {
+	Before string
+	After  string
+}
+
+// A Formatter prints a tree built with Rule and Between back as text.
+// It is told about the operators the same way the tree was, so that
+// it only adds the parentheses the operators' precedence requires.
+type Formatter struct {
+	Indent string // Written once per open Block at the start of each line.
+
+	levels  map[string]int // Operator precedence, lower binds tighter.
+	rules   int
+	parens  [2]string
+	pairs   map[string][2]string
+	blocks  map[string]bool
+	spacing map[string]*Spacing
+}
+
+func NewFormatter() *Formatter {
+	return &Formatter{
+		Indent:  "\t",
+		levels:  map[string]int{},
+		parens:  [2]string{"(", ")"},
+		pairs:   map[string][2]string{},
+		blocks:  map[string]bool{},
+		spacing: map[string]*Spacing{},
+	}
+}
+
+// Rule declares operators of the same precedence, just like Abstract.Rule:
+// operators declared first bind tighter.
+func (self *Formatter) Rule(ops ...*operator) *Formatter {
+	for _, op := range ops {
+		self.levels[op.name] = self.rules
+	}
+	self.rules++
+	return self
+}
+
+func (self *Formatter) Operator(name string, left int, right int) *Formatter {
+	return self.Rule(Operator(name, left, right))
+}
+
+// Between declares a pair of delimiters grouped with Abstract.Between.
+func (self *Formatter) Between(left string, right string) *Formatter {
+	self.pairs[left+right] = [2]string{left, right}
+	return self
+}
+
+// Block is Between for delimiters whose contents go on their own, indented lines.
+func (self *Formatter) Block(left string, right string) *Formatter {
+	self.Between(left, right)
+	self.blocks[left+right] = true
+	return self
+}
+
+// Parens sets the delimiters added around an operator that would
+// otherwise bind the wrong way. They are "(" and ")" by default.
+func (self *Formatter) Parens(left string, right string) *Formatter {
+	self.parens = [2]string{left, right}
+	return self
+}
+
+// Space sets the text written before and after tokens with the given name.
+// Without it, tokens are separated by a single space, except just inside
+// delimiters. Newlines are followed by the indentation of the open blocks.
+func (self *Formatter) Space(name string, before string, after string) *Formatter {
+	self.spacing[name] = &Spacing{before, after}
+	return self
+}
+
+type pieceKind int
+
+const (
+	plainPiece pieceKind = iota
+	openPiece
+	closePiece
+	blockOpenPiece
+	blockClosePiece
+)
+
+type piece struct {
+	name string
+	text string
+	kind pieceKind
+}
+
+func (self *Formatter) Format(tree *Abstract) string {
+	pieces := self.pieces(tree, []*piece{})
+
+	var out bytes.Buffer
+	depth := 0
+	for i, p := range pieces {
+		if p.kind == blockClosePiece {
+			depth--
+		}
+		if i > 0 {
+			separator := self.separator(pieces[i-1], p)
+			out.WriteString(separator)
+			if strings.HasSuffix(separator, "\n") {
+				out.WriteString(strings.Repeat(self.Indent, depth))
+			}
+		}
+		out.WriteString(p.text)
+		if p.kind == blockOpenPiece {
+			depth++
+		}
+	}
+	return out.String()
+}
+
+func (self *Formatter) separator(prev *piece, next *piece) string {
+	if prev.kind == blockOpenPiece || next.kind == blockClosePiece {
+		return "\n"
+	}
+	if spacing, ok := self.spacing[prev.name]; ok {
+		return spacing.After
+	}
+	if spacing, ok := self.spacing[next.name]; ok {
+		return spacing.Before
+	}
+	if prev.kind == openPiece || next.kind == closePiece {
+		return ""
+	}
+	return " "
+}
+
+// The precedence of an operator node made by Rule.
+func (self *Formatter) level(node *Abstract) (int, bool) {
+	if node.Token == nil || len(node.Children) != 2 {
+		return 0, false
+	}
+	if !node.infix && (node.Children[0].Token == nil || node.Children[0].Token.Name != "abstract_right" ||
+		node.Children[1].Token == nil || node.Children[1].Token.Name != "abstract_left") {
+		return 0, false
+	}
+	level, ok := self.levels[node.Token.Name]
+	return level, ok
+}
+
+func (self *Formatter) pieces(node *Abstract, out []*piece) []*piece {
+	if level, ok := self.level(node); ok {
+		for _, operand := range node.Children[0].Children {
+			out = self.operand(operand, level, false, out)
+		}
+		out = append(out, &piece{node.Token.Name, node.Token.Value, plainPiece})
+		for _, operand := range node.Children[1].Children {
+			out = self.operand(operand, level, true, out)
+		}
+		return out
+	}
+
+	if node.Token == nil {
+		for _, child := range node.Children {
+			out = self.pieces(child, out)
+		}
+		return out
+	}
+
+	pair, ok := self.pairs[node.Token.Name]
+	if ok || node.closing != nil {
+		open, close := pair[0], pair[1]
+		if node.closing != nil {
+			pair[1] = node.closing.Token.Name
+			pair[0] = strings.TrimSuffix(node.Token.Name, pair[1])
+			close = node.closing.Token.Value
+			open = strings.TrimSuffix(node.Token.Value, close)
+		}
+		open_kind, close_kind := openPiece, closePiece
+		if self.blocks[node.Token.Name] {
+			open_kind, close_kind = blockOpenPiece, blockClosePiece
+		}
+		out = append(out, &piece{pair[0], open, open_kind})
+		for _, child := range node.Children {
+			out = self.pieces(child, out)
+		}
+		return append(out, &piece{pair[1], close, close_kind})
+	}
+
+	out = append(out, &piece{node.Token.Name, node.Token.Value, plainPiece})
+	for _, child := range node.Children {
+		out = self.pieces(child, out)
+	}
+	return out
+}
+
+// Operators are left-associative, so an operand of the same precedence
+// only needs parentheses on the right, where it would otherwise be
+// grouped with the operator to its left.
+func (self *Formatter) operand(node *Abstract, parent int, right bool, out []*piece) []*piece {
+	level, ok := self.level(node)
+	if !ok || level < parent || (level == parent && (!right || len(node.Children[0].Children) == 0)) {
+		return self.pieces(node, out)
+	}
+	out = append(out, &piece{self.parens[0], self.parens[0], openPiece})
+	out = self.pieces(node, out)
+	return append(out, &piece{self.parens[1], self.parens[1], closePiece})
+}
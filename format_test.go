@@ -0,0 +1,73 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func arithmeticFormatter() *Formatter {
+	f := NewFormatter()
+	f.Operator("*", 1, 1)
+	f.Rule(Operator("+", 1, 1), Operator("-", 1, 1))
+	return f
+}
+
+// Parses with parentheses, then throws the groups away so that only the
+// operators' nesting is left for the Formatter to print.
+func withoutParens(str string) *Abstract {
+	operator := OneOfString("+", "-", "*", "(", ")")
+	tree := AbstractFromResult(Many(OneOf(Digit, operator)).MustCompile(str))
+	tree.Between("(", ")")
+	tree.Operator("*", 1, 1)
+	tree.Rule(Operator("+", 1, 1), Operator("-", 1, 1))
+	return tree.Transform(func(node *Abstract) *Abstract {
+		if node.Token != nil && node.Token.Name == "()" && len(node.Children) == 1 {
+			return node.Children[0]
+		}
+		return node
+	})
+}
+
+func TestFormat(t *testing.T) {
+	f := arithmeticFormatter()
+	if out := f.Format(arithmeticTree("1+2*3")); out != "1 + 2 * 3" {
+		t.Errorf("Format gives %q", out)
+	}
+
+	expected := map[string]string{
+		"(1+2)*3":   "(1 + 2) * 3",
+		"1*(2+3)":   "1 * (2 + 3)",
+		"1-(2-3)":   "1 - (2 - 3)",
+		"(1-2)-3":   "1 - 2 - 3",
+		"(1*2)+3":   "1 * 2 + 3",
+		"((1))+(2)": "1 + 2",
+	}
+	for str, formatted := range expected {
+		if out := f.Format(withoutParens(str)); out != formatted {
+			t.Errorf("Format gives %q instead of %q for %s", out, formatted, str)
+		}
+	}
+
+	// Groups still in the tree are printed with their delimiters.
+	tree := AbstractFromResult(Many(OneOf(Digit, OneOfString("+", "(", ")"))).MustCompile("(1+2)"))
+	tree.Between("(", ")")
+	tree.Operator("+", 1, 1)
+	if out := f.Format(tree); out != "(1 + 2)" {
+		t.Errorf("Format gives %q for a group", out)
+	}
+}
+
+func TestFormatSpacing(t *testing.T) {
+	lexer := Many(OneOf(Lower, OneOfString("{", "}", ";", ",")))
+	tree := AbstractFromResult(lexer.MustCompile("{a,b;{c;}}"))
+	tree.Between("{", "}")
+
+	f := NewFormatter()
+	f.Indent = "  "
+	f.Block("{", "}")
+	f.Space(";", "", "\n")
+	f.Space(",", "", " ")
+	expected := "{\n  a, b;\n  {\n    c;\n  }\n}"
+	if out := f.Format(tree); out != expected {
+		t.Errorf("Format gives %q instead of %q", out, expected)
+	}
+}
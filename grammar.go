@@ -0,0 +1,246 @@
+package abstract
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//// Grammars.
+
+// A Grammar puts the lexer and the tree rules of a language in one place:
+// Parse lexes the input, runs the rewriters, filters out the skipped
+// tokens, groups the delimiters and applies the operator rules, in that order.
+//
+// Declare everything before the first call to Parse; declaring more after
+// Validate makes it check again. After that, a Grammar is only read from,
+// so Parse can be called from several goroutines at once.
+type Grammar struct {
+	lexer     *Lexer
+	rewriters []Rewriter
+	added     []string // The names of the tokens the rewriters add.
+	skip      []string
+	pairs     [][2]string
+	rules     [][]*operator
+
+	lock      sync.Mutex // Guards validated and err.
+	validated bool
+	err       error
+}
+
+func NewGrammar(lexer *Lexer) *Grammar {
+	return &Grammar{lexer: lexer}
+}
+
+// Rewrite runs rewriter on the tokens before anything else, after the
+// rewriters of earlier calls. names are the tokens it adds, for Validate;
+// Offside and Insertions don't need them.
+func (self *Grammar) Rewrite(rewriter Rewriter, names ...string) *Grammar {
+	self.declared()
+	self.rewriters = append(self.rewriters, rewriter)
+	self.added = append(self.added, names...)
+	if r, ok := rewriter.(interface{ produces() []string }); ok {
+		self.added = append(self.added, r.produces()...)
+	}
+	return self
+}
+
+// Skip removes the tokens with these names before the tree is built.
+func (self *Grammar) Skip(names ...string) *Grammar {
+	self.declared()
+	self.skip = append(self.skip, names...)
+	return self
+}
+
+func (self *Grammar) Between(left string, right string) *Grammar {
+	self.declared()
+	self.pairs = append(self.pairs, [2]string{left, right})
+	return self
+}
+
+// Rule adds operators of the same precedence. Like Abstract.Rule,
+// the operators of earlier calls bind tighter.
+func (self *Grammar) Rule(ops ...*operator) *Grammar {
+	self.declared()
+	self.rules = append(self.rules, ops)
+	return self
+}
+
+func (self *Grammar) Operator(name string, left int, right int) *Grammar {
+	return self.Rule(Operator(name, left, right))
+}
+
+// Forgets what Validate found, which no longer holds.
+func (self *Grammar) declared() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.validated = false
+	self.err = nil
+}
+
+// Validate checks that every token name the grammar refers to
+// can actually be produced by its lexer.
+func (self *Grammar) Validate() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if !self.validated {
+		self.err = self.validate()
+		self.validated = true
+	}
+	return self.err
+}
+
+func (self *Grammar) validate() error {
+	if self.lexer == nil {
+		return errors.New("Grammar has no lexer.")
+	}
+	names := self.lexer.names()
+	for _, name := range self.added {
+		names.names[name] = true
+	}
+	problems := []string{}
+
+	for _, name := range self.skip {
+		if !names.has(name) {
+			problems = append(problems, fmt.Sprintf("skipped token %q is never produced", name))
+		}
+	}
+	for _, pair := range self.pairs {
+		for _, name := range pair {
+			if !names.has(name) {
+				problems = append(problems, fmt.Sprintf("delimiter %q is never produced", name))
+			}
+		}
+	}
+	// Operators may also apply to the groups made by Between.
+	for _, pair := range self.pairs {
+		names.names[pair[0]+pair[1]] = true
+	}
+	for _, rule := range self.rules {
+		for _, op := range rule {
+			if !names.has(op.name) {
+				problems = append(problems, fmt.Sprintf("operator %q is never produced", op.name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Invalid grammar: " + strings.Join(problems, ", ") + ".")
+	}
+	return nil
+}
+
+// The names of the tokens a lexer can produce. Classes and backrefs name
+// their tokens after the text they match, so they are kept to check names
+// against instead.
+type producedNames struct {
+	names   map[string]bool
+	classes []class
+	any     bool // A Backref can give a token for any text.
+}
+
+func (self *producedNames) has(name string) bool {
+	if self.names[name] || self.any {
+		return true
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	if size == 0 || size != len(name) {
+		return false
+	}
+	for _, c := range self.classes {
+		if c.contains(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Lexer) names() *producedNames {
+	produced := &producedNames{names: map[string]bool{}}
+	names := produced.names
+	visited := map[*Lexer]bool{}
+	var visit func(*Lexer)
+	visit = func(lexer *Lexer) {
+		if visited[lexer] {
+			return
+		}
+		visited[lexer] = true
+		if lexer.token != "" {
+			// An alias hides the tokens of its children.
+			names[lexer.token] = true
+			return
+		}
+		if lexer.action == PEEK || lexer.action == NOT || lexer.action == UNTIL {
+			return // Lookaheads produce no tokens, Until only text.
+		}
+		if lexer.action == CLASS {
+			produced.classes = append(produced.classes, lexer.class)
+		}
+		if lexer.action == BACKREF {
+			produced.any = true
+		}
+		if lexer.action == PUSH {
+			names["push"] = true
+		}
+		if lexer.action == POP {
+			names["pop"] = true
+		}
+		if lexer.action == KEYWORDS {
+			lexer.keywords.words.walk(func(word string) {
+				names[word] = true
+			})
+		}
+		if lexer.action == SKIP {
+			// What is skipped becomes garbage.
+			names[garbage] = true
+			for _, child := range lexer.children[1:] {
+				visit(child)
+			}
+			return
+		}
+		for _, child := range lexer.children {
+			visit(child)
+		}
+	}
+	visit(self)
+	return produced
+}
+
+func (self *Grammar) Parse(input string) (tree *Abstract, err error) {
+	if err := self.Validate(); err != nil {
+		return nil, err
+	}
+
+	result, err := self.lexer.compileAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Between and Rule panic on input they can't make sense of.
+	defer func() {
+		if r := recover(); r != nil {
+			tree = nil
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	tokens := result.Tokens()
+	for _, rewriter := range self.rewriters {
+		if tokens, err = rewriter.Rewrite(tokens); err != nil {
+			return nil, err
+		}
+	}
+	tree = AbstractParent(tokens)
+	for _, name := range self.skip {
+		tree.Filter(name)
+	}
+	for _, pair := range self.pairs {
+		tree.Between(pair[0], pair[1])
+	}
+	for _, rule := range self.rules {
+		tree.Rule(rule...)
+	}
+	return tree, nil
+}
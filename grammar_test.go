@@ -0,0 +1,109 @@
+package abstract
+
+import (
+	"sync"
+	"testing"
+)
+
+func arithmeticGrammar() *Grammar {
+	spaces := Munch(Space).Alias("space")
+	number := Munch(Digit).Alias("number")
+	lexer := Many(OneOf(number, OneOfString("+", "-", "*", "(", ")"), spaces))
+
+	return NewGrammar(lexer).
+		Skip("space").
+		Between("(", ")").
+		Operator("*", 1, 1).
+		Rule(Operator("+", 1, 1), Operator("-", 1, 1))
+}
+
+func TestGrammar(t *testing.T) {
+	grammar := arithmeticGrammar()
+	tree, err := grammar.Parse("2 + (4 * 2) - 3")
+	if err != nil {
+		t.Fatalf("Grammar does not parse: %s", err)
+	}
+
+	expected := AbstractFromResult(Many(OneOf(Munch(Digit).Alias("number"), OneOfString("+", "-", "*", "(", ")"))).MustCompile("2+(4*2)-3"))
+	expected.Between("(", ")")
+	expected.Operator("*", 1, 1)
+	expected.Rule(Operator("+", 1, 1), Operator("-", 1, 1))
+	if !tree.Equal(expected) {
+		t.Errorf("Grammar gives %s instead of %s", tree, expected)
+	}
+
+	if _, err := grammar.Parse("2 + x"); err == nil {
+		t.Error("Grammar parses input the lexer doesn't accept")
+	}
+	if _, err := grammar.Parse("2 +"); err == nil {
+		t.Error("Grammar parses an operator without an operand")
+	}
+}
+
+func TestGrammarValidate(t *testing.T) {
+	if err := arithmeticGrammar().Validate(); err != nil {
+		t.Errorf("Valid grammar does not validate: %s", err)
+	}
+
+	grammar := arithmeticGrammar().Operator("/", 1, 1).Skip("comment")
+	err := grammar.Validate()
+	if err == nil || err.Error() != `Invalid grammar: skipped token "comment" is never produced, operator "/" is never produced.` {
+		t.Errorf("Grammar does not report unknown tokens: %v", err)
+	}
+	if _, err := grammar.Parse("1"); err == nil {
+		t.Error("Invalid grammar parses")
+	}
+
+	// Aliased tokens hide the tokens they're made of.
+	grammar = NewGrammar(And(Lex("a"), Lex("b")).Alias("ab")).Operator("a", 0, 1)
+	if grammar.Validate() == nil {
+		t.Error("Grammar accepts a token hidden by an alias")
+	}
+}
+
+func TestGrammarValidateAgain(t *testing.T) {
+	grammar := arithmeticGrammar()
+	if err := grammar.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	grammar.Operator("/", 1, 1)
+	if _, err := grammar.Parse("1 + 2"); err == nil {
+		t.Error("Grammar keeps the result of an earlier Validate")
+	}
+}
+
+func TestGrammarValidateClasses(t *testing.T) {
+	// Classes and backrefs name their tokens after the text they match.
+	lexer := Many(OneOf(Munch(Digit).Alias("number"), Lex("+"), Munch(Space)))
+	if err := NewGrammar(lexer).Skip(" ").Validate(); err != nil {
+		t.Errorf("Validate does not know the characters of a class: %s", err)
+	}
+	if err := NewGrammar(Union(Lex("+"), Lex("-"))).Operator("+", 1, 1).Validate(); err != nil {
+		t.Errorf("Validate does not know the characters of a union: %s", err)
+	}
+	if err := NewGrammar(Munch(Digit)).Operator("+", 1, 1).Validate(); err == nil {
+		t.Error("Validate accepts a character the class doesn't have")
+	}
+	backref := And(Capture("op", OneOfString("+", "-")), Backref("op"))
+	if err := NewGrammar(backref).Operator("++", 1, 1).Validate(); err != nil {
+		t.Errorf("Validate does not know the tokens of a backref: %s", err)
+	}
+}
+
+func TestGrammarConcurrency(t *testing.T) {
+	grammar := arithmeticGrammar()
+	expected, _ := grammar.Parse("1 + 2 * (3 - 4)")
+
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			tree, err := grammar.Parse("1 + 2 * (3 - 4)")
+			if err != nil || !tree.Equal(expected) {
+				t.Error("Concurrent parses give different trees")
+			}
+		}()
+	}
+	wait.Wait()
+}
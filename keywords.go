@@ -0,0 +1,133 @@
+package abstract
+
+import (
+	"unicode"
+)
+
+//// Keywords.
+
+// The words of a Keywords lexer, in a trie by character.
+type trie struct {
+	next    map[rune]*trie
+	word    string
+	is_word bool
+}
+
+type keywords struct {
+	words      *trie
+	fold       bool
+	contextual bool
+}
+
+// The character every character of its case folding orbit is looked up by.
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+func (self *trie) insert(word string, fold bool) {
+	node := self
+	for _, r := range word {
+		if fold {
+			r = foldRune(r)
+		}
+		if node.next[r] == nil {
+			node.next[r] = &trie{next: map[rune]*trie{}}
+		}
+		node = node.next[r]
+	}
+	node.word, node.is_word = word, true
+}
+
+// Returns the word text is, as it was inserted.
+func (self *trie) lookup(text string, fold bool) (string, bool) {
+	node := self
+	for _, r := range text {
+		if fold {
+			r = foldRune(r)
+		}
+		if node = node.next[r]; node == nil {
+			return "", false
+		}
+	}
+	return node.word, node.is_word
+}
+
+func (self *trie) walk(f func(string)) {
+	if self.is_word {
+		f(self.word)
+	}
+	for _, node := range self.next {
+		node.walk(f)
+	}
+}
+
+// Keywords matches what ident matches, taking the longest match, and then
+// turns the tokens into a single keyword token if the text is one of words.
+// The keyword token is named after the word, like Lex(word) would make it,
+// so "if iffy" gives "if" and then whatever ident makes of "iffy", where
+// OneOf(Lex("if"), ident) gives both readings of "if".
+func Keywords(ident *Lexer, words ...string) *Lexer {
+	b := base()
+	b.action = KEYWORDS
+	b.children = append(b.children, ident)
+	b.keywords = &keywords{words: &trie{next: map[rune]*trie{}}}
+	for _, word := range words {
+		b.keywords.words.insert(word, false)
+	}
+	return b
+}
+
+// KeywordsFold is Keywords, with the words in any case. The keyword token
+// is named after the word, and holds the text as it was typed.
+func KeywordsFold(ident *Lexer, words ...string) *Lexer {
+	b := Keywords(ident)
+	b.keywords.fold = true
+	for _, word := range words {
+		b.keywords.words.insert(word, true)
+	}
+	return b
+}
+
+// Contextual returns a copy of a Keywords lexer whose words are keywords
+// only where the lexers around them allow it: a word gives the keyword
+// first, then the tokens of ident, as two results.
+func (self *Lexer) Contextual() *Lexer {
+	if self.action != KEYWORDS {
+		panic("Contextual only applies to Keywords and KeywordsFold.")
+	}
+	lexer := *self
+	words := *self.keywords
+	words.contextual = true
+	lexer.keywords = &words
+	return &lexer
+}
+
+func (self *Lexer) compileKeywords(str string, captures *binding) []*Result {
+	var longest *Result
+	for _, result := range self.children[0].compile(str, captures) {
+		if longest == nil || len(result.left_over) < len(longest.left_over) {
+			longest = result
+		}
+	}
+	if longest == nil {
+		return []*Result{}
+	}
+
+	text := str[:len(str)-len(longest.left_over)]
+	word, ok := self.keywords.words.lookup(text, self.keywords.fold)
+	if !ok {
+		return []*Result{longest}
+	}
+	tok := &Token{Name: word, Value: text, raw: text, has_raw: true}
+	keyword := &Result{tokens: []*Token{tok}, left_over: longest.left_over, captures: longest.captures}
+	if self.keywords.contextual {
+		return []*Result{keyword, longest}
+	}
+	return []*Result{keyword}
+}
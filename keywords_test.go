@@ -0,0 +1,91 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeywords(t *testing.T) {
+	ident := Munch(Alpha).Alias("ident")
+	keyword := Keywords(ident, "if", "else", "elif")
+	lexer := Maybe(Munch(OneOf(keyword, Munch(Space).Garbage())))
+
+	results := lexer.Compile("if iffy el elif")
+	complete := []*Result{}
+	for _, result := range results {
+		if result.left_over == "" {
+			complete = append(complete, result)
+		}
+	}
+	if len(complete) != 1 {
+		t.Fatalf("Keywords gives %d complete results", len(complete))
+	}
+	tokens := []string{}
+	for _, tok := range complete[0].Tokens() {
+		if tok.Name != garbage {
+			tokens = append(tokens, tok.String())
+		}
+	}
+	if strings.Join(tokens, " ") != "if:if ident:iffy ident:el elif:elif" {
+		t.Errorf("The tokens are %s", strings.Join(tokens, " "))
+	}
+	if keyword.Match("1") {
+		t.Error("Keywords matches what ident doesn't")
+	}
+}
+
+func TestKeywordsFold(t *testing.T) {
+	keyword := KeywordsFold(Munch(Alpha).Alias("ident"), "select", "from")
+	tok := keyword.MustCompile("SeLeCt").Tokens()[0]
+	if tok.Name != "select" || tok.Value != "SeLeCt" {
+		t.Errorf("KeywordsFold gives %s", tok)
+	}
+	if tok := keyword.MustCompile("selects").Tokens()[0]; tok.Name != "ident" {
+		t.Errorf("KeywordsFold gives %s", tok)
+	}
+	if tok := Keywords(Munch(Alpha).Alias("ident"), "select").MustCompile("SELECT").Tokens()[0]; tok.Name != "ident" {
+		t.Error("Keywords ignores case")
+	}
+}
+
+func TestContextual(t *testing.T) {
+	ident := Munch(Alpha).Alias("ident")
+	strict := Keywords(ident, "var")
+	contextual := strict.Contextual()
+
+	if len(contextual.Compile("var")) != 2 || len(strict.Compile("var")) != 1 {
+		t.Error("Contextual does not give both readings")
+	}
+
+	// "var var" declares a variable named var, so the second var must be an ident.
+	declares := func(name *Lexer) bool {
+		for _, result := range And(Lex("var"), Munch(Space).Garbage(), name).Compile("var var") {
+			tokens := result.Tokens()
+			if result.left_over == "" && tokens[len(tokens)-1].Name == "ident" {
+				return true
+			}
+		}
+		return false
+	}
+	if declares(strict) {
+		t.Error("Keywords reads a keyword as an ident")
+	}
+	if !declares(contextual) {
+		t.Error("Contextual does not read the keyword as an ident where one is needed")
+	}
+	if tokens := contextual.MustCompile("var").Tokens(); tokens[0].Name != "var" {
+		t.Errorf("The keyword does not come first: %v", tokens)
+	}
+
+	if err := NewGrammar(Munch(OneOf(strict, Lex(" ")))).Operator("var", 0, 1).Validate(); err != nil {
+		t.Errorf("Validate does not know the keywords: %s", err)
+	}
+	// Keywords match the same text as ident, so the words go in a comment.
+	ebnf := And(KeywordsFold(ident, "var", "let").Contextual(), Lex(";")).EBNF()
+	if !strings.HasPrefix(ebnf, "main = keywords \";\" ;\nkeywords = ident ; # or a keyword: \"let\" \"var\", in any case, where allowed\n") {
+		t.Errorf("EBNF writes %s", ebnf)
+	}
+	if rules, err := LoadRules(ebnf); err != nil || !rules["main"].Match("x;") {
+		t.Errorf("The EBNF does not load back: %v", err)
+	}
+}
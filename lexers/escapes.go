@@ -0,0 +1,122 @@
+package lexers
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The escapes that stand for a single character in C and Python.
+var charEscapes = map[byte]string{
+	'a': "\a", 'b': "\b", 'f': "\f", 'n': "\n", 'r': "\r", 't': "\t", 'v': "\v",
+	'\\': "\\", '\'': "'", '"': "\"",
+}
+
+// Reads between least and most digits of base from the start of text,
+// or as many as there are if most is negative.
+func number(text string, base int, least int, most int) (rune, int, bool) {
+	const digits = "0123456789abcdef"
+	size := 0
+	for size < len(text) && (most < 0 || size < most) && strings.IndexByte(digits[:base], text[size]|0x20) >= 0 {
+		size++
+	}
+	if size < least || size == 0 {
+		return 0, 0, false
+	}
+	value, err := strconv.ParseUint(text[:size], base, 32)
+	if err != nil || value > utf8.MaxRune {
+		return 0, 0, false
+	}
+	return rune(value), size, true
+}
+
+// A code point as UTF-8, if it is one.
+func codePoint(value rune, ok bool) (string, bool) {
+	if !ok || !utf8.ValidRune(value) {
+		return "", false
+	}
+	return string(value), true
+}
+
+// Decodes the escapes of Go's interpreted strings, like strconv.Unquote.
+// \x and octal escapes give single bytes.
+func goUnescape(text string) (string, int, bool) {
+	value, multibyte, tail, err := strconv.UnquoteChar("\\"+text, '"')
+	if err != nil {
+		return "", 0, false
+	}
+	size := len(text) - len(tail)
+	if value >= utf8.RuneSelf && !multibyte {
+		return string([]byte{byte(value)}), size, true
+	}
+	return string(value), size, true
+}
+
+// Decodes C's escapes: \n and the like, up to three octal digits, \x and
+// its hex digits, which give single bytes, and \u and \U with four and
+// eight hex digits, which give UTF-8.
+func cUnescape(text string) (string, int, bool) {
+	if text == "" {
+		return "", 0, false
+	}
+	if value, ok := charEscapes[text[0]]; ok {
+		return value, 1, true
+	}
+	switch text[0] {
+	case '?':
+		return "?", 1, true
+	case 'x':
+		value, size, ok := number(text[1:], 16, 1, -1)
+		if !ok || value > 0xff {
+			return "", 0, false
+		}
+		return string([]byte{byte(value)}), size + 1, true
+	case 'u', 'U':
+		digits := 4
+		if text[0] == 'U' {
+			digits = 8
+		}
+		value, size, ok := number(text[1:], 16, digits, digits)
+		str, ok := codePoint(value, ok)
+		return str, size + 1, ok
+	}
+	value, size, ok := number(text, 8, 1, 3)
+	if !ok || value > 0xff {
+		return "", 0, false
+	}
+	return string([]byte{byte(value)}), size, true
+}
+
+// Decodes Python's escapes: \n and the like, a backslash before a newline,
+// which joins the lines, up to three octal digits, \x with two hex digits,
+// and \u and \U with four and eight. These all give code points as UTF-8.
+// Any other escape, \N{name} included, is kept as it is, backslash and all.
+func pythonUnescape(text string) (string, int, bool) {
+	if text == "" {
+		return "", 0, false
+	}
+	if value, ok := charEscapes[text[0]]; ok {
+		return value, 1, true
+	}
+	digits := 0
+	switch text[0] {
+	case '\n':
+		return "", 1, true
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	}
+	if digits > 0 {
+		value, size, ok := number(text[1:], 16, digits, digits)
+		str, ok := codePoint(value, ok)
+		return str, size + 1, ok
+	}
+	if value, size, ok := number(text, 8, 1, 3); ok {
+		return string(value), size, true
+	}
+	_, size := utf8.DecodeRuneInString(text)
+	return "\\" + text[:size], size, true
+}
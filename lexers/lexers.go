@@ -0,0 +1,107 @@
+// Package lexers has ready-made lexers for the literals most languages
+// share. Their tokens are named "int", "float", "ident", "string" and
+// "comment", so they combine with OneOf and FirstOf:
+//
+//	token := FirstOf(lexers.Float(), lexers.Hex(), lexers.Int(), lexers.Ident())
+package lexers
+
+import (
+	"abstract"
+)
+
+// Matches lexer once or not at all, without giving a result for both.
+func optional(lexer *abstract.Lexer) *abstract.Lexer {
+	return abstract.NMunch(lexer, 0, 1)
+}
+
+// Digits, allowing single underscores between them, which are left
+// out of the token's value: "1_000" holds "1000".
+func digits(digit *abstract.Lexer) *abstract.Lexer {
+	underscore := abstract.Lex("_").Garbage()
+	return abstract.And(digit, abstract.Maybe(abstract.Munch(abstract.And(optional(underscore), digit))))
+}
+
+// A prefix like "0x", in either case.
+func prefixed(prefix string, digit *abstract.Lexer) *abstract.Lexer {
+	return abstract.And(abstract.LexFold(prefix), optional(abstract.Lex("_").Garbage()), digits(digit)).Alias("int")
+}
+
+// Int matches a decimal integer like 42 or 1_000.
+func Int() *abstract.Lexer {
+	return digits(abstract.Digit).Alias("int")
+}
+
+// Float matches a decimal number with a fraction or an exponent, like
+// 1.5, 1., .5, 1e10 or 2.5E-3.
+func Float() *abstract.Lexer {
+	exponent := abstract.And(
+		abstract.LexFold("e"),
+		optional(abstract.Union(abstract.Lex("+"), abstract.Lex("-"))),
+		digits(abstract.Digit))
+	return abstract.FirstOf(
+		abstract.And(digits(abstract.Digit), abstract.Lex("."), optional(digits(abstract.Digit)), optional(exponent)),
+		abstract.And(abstract.Lex("."), digits(abstract.Digit), optional(exponent)),
+		abstract.And(digits(abstract.Digit), exponent),
+	).Alias("float")
+}
+
+// Hex matches a hexadecimal integer like 0xFF, keeping the prefix in its
+// value so strconv.ParseInt(value, 0, 64) reads it.
+func Hex() *abstract.Lexer {
+	return prefixed("0x", abstract.Union(abstract.Digit, abstract.Range('a', 'f'), abstract.Range('A', 'F')))
+}
+
+// Octal matches an octal integer like 0o755.
+func Octal() *abstract.Lexer {
+	return prefixed("0o", abstract.Range('0', '7'))
+}
+
+// Binary matches a binary integer like 0b1010.
+func Binary() *abstract.Lexer {
+	return prefixed("0b", abstract.Range('0', '1'))
+}
+
+// Ident matches an identifier: a letter or an underscore, then letters,
+// digits and underscores, in any script.
+func Ident() *abstract.Lexer {
+	first := abstract.Union(abstract.Letter, abstract.Lex("_"))
+	rest := abstract.Union(abstract.Letter, abstract.Category("Nd"), abstract.Lex("_"))
+	return abstract.And(first, abstract.Maybe(abstract.Munch(rest))).Alias("ident")
+}
+
+// GoString matches a Go string, either "interpreted" or `raw`, decoding
+// the escapes of interpreted strings like strconv.Unquote.
+func GoString() *abstract.Lexer {
+	return abstract.FirstOf(abstract.StringLiteral("\"", "\\").Unescape(goUnescape), abstract.StringLiteral("`", ""))
+}
+
+// CString matches a C string in double quotes, decoding its escapes.
+func CString() *abstract.Lexer {
+	return abstract.StringLiteral("\"", "\\").Unescape(cUnescape)
+}
+
+// PythonString matches a Python string in single, double or triple quotes,
+// decoding its escapes but for \N{name}. Prefixes like r and b are not
+// included.
+func PythonString() *abstract.Lexer {
+	quoted := func(quote string) *abstract.Lexer {
+		return abstract.StringLiteral(quote, "\\").Unescape(pythonUnescape)
+	}
+	return abstract.FirstOf(quoted("\"\"\""), quoted("'''"), quoted("\""), quoted("'"))
+}
+
+// LineComment matches prefix and the rest of the line, leaving the
+// newline for the next lexer.
+func LineComment(prefix string) *abstract.Lexer {
+	end := abstract.OneOf(abstract.Lex("\n"), abstract.Eof)
+	return abstract.And(abstract.Lex(prefix), abstract.Until(end)).Alias("comment")
+}
+
+// BlockComment matches a comment between open and close, which nest if
+// nested is true.
+func BlockComment(open string, close string, nested bool) *abstract.Lexer {
+	if nested {
+		return abstract.Nested(abstract.Lex(open), abstract.Lex(close)).Alias("comment")
+	}
+	return abstract.Delimited(abstract.Lex(open), abstract.Any(), abstract.Lex(close)).Alias("comment")
+}
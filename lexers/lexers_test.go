@@ -0,0 +1,117 @@
+package lexers
+
+import (
+	"abstract"
+	"testing"
+)
+
+// The name and value of the single token lexer makes of all of str.
+func token(lexer *abstract.Lexer, str string) (string, string) {
+	tokens := lexer.MustCompile(str).Tokens()
+	if len(tokens) != 1 {
+		return "", ""
+	}
+	return tokens[0].Name, tokens[0].Value
+}
+
+func TestNumbers(t *testing.T) {
+	numbers := map[string][2]string{
+		"42":     {"int", "42"},
+		"1_000":  {"int", "1000"},
+		"1.5":    {"float", "1.5"},
+		"1.":     {"float", "1."},
+		".5":     {"float", ".5"},
+		"2.5E-3": {"float", "2.5E-3"},
+		"1e10":   {"float", "1e10"},
+		"0xFF":   {"int", "0xFF"},
+		"0x_f_f": {"int", "0xff"},
+		"0o755":  {"int", "0o755"},
+		"0B1010": {"int", "0B1010"},
+	}
+	number := abstract.FirstOf(Hex(), Octal(), Binary(), Float(), Int())
+	for str, expected := range numbers {
+		name, value := token(number, str)
+		if name != expected[0] || value != expected[1] {
+			t.Errorf("%q lexes as %s %q instead of %s %q", str, name, value, expected[0], expected[1])
+		}
+	}
+	for _, str := range []string{"1__0", "_1", "0x", "0b2", "1e"} {
+		if result, ok := compiles(number, str); ok {
+			t.Errorf("%q lexes as %v", str, result.Tokens())
+		}
+	}
+}
+
+func compiles(lexer *abstract.Lexer, str string) (result *abstract.Result, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return lexer.MustCompile(str), true
+}
+
+func TestIdent(t *testing.T) {
+	for _, str := range []string{"x", "_tmp1", "größe", "变量", "αβ2"} {
+		if name, value := token(Ident(), str); name != "ident" || value != str {
+			t.Errorf("%q lexes as %s %q", str, name, value)
+		}
+	}
+	if _, ok := compiles(Ident(), "1x"); ok {
+		t.Error("Ident matches an identifier starting with a digit")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	strings := map[*abstract.Lexer]map[string]string{
+		GoString():     {`"a\tb"`: "a\tb", "`a\\tb`": "a\\tb"},
+		CString():      {`"say \"hi\""`: `say "hi"`},
+		PythonString(): {`'it\'s'`: "it's", `"""a "b" c"""`: `a "b" c`, `''''''`: ""},
+	}
+	for lexer, cases := range strings {
+		for str, expected := range cases {
+			if name, value := token(lexer, str); name != "string" || value != expected {
+				t.Errorf("%s lexes as %s %q instead of %q", str, name, value, expected)
+			}
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	escapes := map[*abstract.Lexer]map[string]string{
+		GoString():     {`"\x41\u00e9\101\xff\U0001F600"`: "A\u00e9A\xff\U0001F600"},
+		CString():      {`"\x41\u00e9\101\xff\?\0"`: "A\u00e9A\xff?\x00"},
+		PythonString(): {`'\x41\u00e9\101\xff\N{DASH}\q'`: "A\u00e9A\u00ff\\N{DASH}\\q", "'a\\\nb'": "ab"},
+	}
+	for lexer, cases := range escapes {
+		for str, expected := range cases {
+			if name, value := token(lexer, str); name != "string" || value != expected {
+				t.Errorf("%s lexes as %s %q instead of %q", str, name, value, expected)
+			}
+		}
+	}
+
+	invalid := map[*abstract.Lexer][]string{
+		GoString():     {`"\q"`, `"\'"`, `"\x4"`, `"\400"`, `"\uD800"`},
+		CString():      {`"\q"`, `"\x"`, `"\x100"`, `"\u12"`},
+		PythonString(): {`'\x4'`, `'\u12'`, `'\U00110000'`},
+	}
+	for lexer, cases := range invalid {
+		for _, str := range cases {
+			if result, ok := compiles(lexer, str); ok {
+				t.Errorf("%s lexes as %v", str, result.Tokens())
+			}
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	lexer := abstract.And(LineComment("#"), abstract.Lex("\n"), BlockComment("(*", "*)", true))
+	tokens := lexer.MustCompile("# one\n(* two (* three *) *)").Tokens()
+	if len(tokens) != 3 || tokens[0].Name != "comment" || tokens[2].Value != "(* two (* three *) *)" {
+		t.Errorf("The comments lex as %v", tokens)
+	}
+	if _, ok := compiles(BlockComment("/*", "*/", false), "/* a /* b */ c */"); ok {
+		t.Error("BlockComment nests when it shouldn't")
+	}
+}
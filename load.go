@@ -0,0 +1,525 @@
+package abstract
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//// Text Grammars.
+//
+//	# Comments run to the end of the line.
+//	number = digit+ ("." digit+)? @number ;
+//	space  = ~[ \t\n]+ ;
+//	main   = (number | space | "+" | "-")+ ;
+//
+// Rules are sequences of literals, [character classes] (or [^negated] ones),
+// . for any character, references to other rules and parenthesized groups,
+// with the following operators:
+//
+//	"abc"i    LexFold("abc")
+//	a | b     OneOf(a, b)
+//	a / b     FirstOf(a, b)
+//	a*        Repeat(a, 0, UNBOUNDED, POSSESSIVE), like Maybe(Munch(a))
+//	a+        Repeat(a, 1, UNBOUNDED, POSSESSIVE), like Munch(a)
+//	a?        Maybe(a)
+//	a{m,n}    Repeat(a, m, n, GREEDY), a{m,} has no upper limit, a{m} is exactly m
+//	a{m,n}?   Repeat(a, m, n, LAZY)
+//	a{m,n}+   Repeat(a, m, n, POSSESSIVE)
+//	~a        Garbage(a)
+//	&a        Peek(a)
+//	!a        Not(a)
+//	a b @name Alias(And(a, b), "name")
+//	<name: a> Capture("name", a)
+//	<=name>   Backref("name")
+//
+// Classes can hold Unicode categories and scripts, like [\p{Lu}\p{Greek}_].
+// The rules digit, lower, upper, alpha, alphanumeric, space, letter, number,
+// punct and eof are predefined as the lexers of the same name.
+
+// LoadRules builds a Lexer for every rule of a text grammar.
+func LoadRules(text string) (map[string]*Lexer, error) {
+	parser := &ruleParser{text: []rune(text)}
+	rules := newRuleSet(parser.text, builtinRules(), func(name string) string { return name })
+
+	for {
+		parser.skipSpace()
+		if parser.done() {
+			break
+		}
+		name, err := parser.rule()
+		if err != nil {
+			return nil, err
+		}
+		pos := parser.pos
+		if !parser.accept('=') {
+			return nil, parser.error(pos, "expected '=' after rule name %q", name)
+		}
+		expr, err := parser.choice()
+		if err != nil {
+			return nil, err
+		}
+		pos = parser.pos
+		if !parser.accept(';') {
+			return nil, parser.error(pos, "expected ';' at the end of rule %q", name)
+		}
+		if _, ok := rules.defs[name]; ok {
+			return nil, parser.error(pos, "rule %q is defined twice", name)
+		}
+		rules.define(name, expr)
+	}
+	return rules.build()
+}
+
+func builtinRules() map[string]*Lexer {
+	return map[string]*Lexer{
+		"digit":        Digit,
+		"lower":        Lower,
+		"upper":        Upper,
+		"alpha":        Alpha,
+		"alphanumeric": Alphanumeric,
+		"space":        Space,
+		"letter":       Letter,
+		"number":       Number,
+		"punct":        Punct,
+		"eof":          Eof,
+	}
+}
+
+type ruleParser struct {
+	text []rune
+	pos  int
+}
+
+func (self *ruleParser) error(pos int, format string, args ...interface{}) error {
+	return grammarError(self.text, pos, format, args...)
+}
+
+func (self *ruleParser) done() bool {
+	return self.pos >= len(self.text)
+}
+
+func (self *ruleParser) peek() rune {
+	if self.done() {
+		return 0
+	}
+	return self.text[self.pos]
+}
+
+func (self *ruleParser) skipSpace() {
+	for !self.done() {
+		r := self.peek()
+		if r == '#' {
+			for !self.done() && self.peek() != '\n' {
+				self.pos++
+			}
+		} else if unicode.IsSpace(r) {
+			self.pos++
+		} else {
+			return
+		}
+	}
+}
+
+// Skips spaces, then consumes r if it comes next.
+func (self *ruleParser) accept(r rune) bool {
+	self.skipSpace()
+	if !self.done() && self.peek() == r {
+		self.pos++
+		return true
+	}
+	return false
+}
+
+func isRuleRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && (r == '-' || unicode.IsDigit(r)))
+}
+
+func (self *ruleParser) rule() (string, error) {
+	self.skipSpace()
+	start := self.pos
+	for !self.done() && isRuleRune(self.peek(), self.pos == start) {
+		self.pos++
+	}
+	if start == self.pos {
+		return "", self.error(start, "expected a rule name")
+	}
+	return string(self.text[start:self.pos]), nil
+}
+
+func (self *ruleParser) choice() (*ruleExpr, error) {
+	self.skipSpace()
+	start := self.pos
+	first, err := self.sequence()
+	if err != nil {
+		return nil, err
+	}
+
+	kind := ruleAnd // No alternatives yet.
+	alternatives := []*ruleExpr{first}
+	for {
+		self.skipSpace()
+		pos := self.pos
+		var next ruleKind
+		switch {
+		case self.accept('|'):
+			next = ruleOneOf
+		case self.accept('/'):
+			next = ruleFirstOf
+		default:
+			if kind == ruleAnd {
+				return first, nil
+			}
+			return &ruleExpr{kind: kind, children: alternatives, pos: start}, nil
+		}
+		if kind != ruleAnd && kind != next {
+			return nil, self.error(pos, "'|' and '/' can't be mixed without parentheses")
+		}
+		kind = next
+		expr, err := self.sequence()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, expr)
+	}
+}
+
+func (self *ruleParser) sequence() (*ruleExpr, error) {
+	self.skipSpace()
+	start := self.pos
+	items := []*ruleExpr{}
+	for {
+		self.skipSpace()
+		if self.done() || strings.ContainsRune("|/);@>", self.peek()) {
+			break
+		}
+		item, err := self.prefixed()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, self.error(self.pos, "expected an expression")
+	}
+
+	expr := &ruleExpr{kind: ruleAnd, children: items, pos: start}
+	if self.accept('@') {
+		name, err := self.rule()
+		if err != nil {
+			return nil, err
+		}
+		expr = &ruleExpr{kind: ruleAlias, text: name, children: []*ruleExpr{expr}, pos: start}
+	}
+	return expr, nil
+}
+
+func (self *ruleParser) prefixed() (*ruleExpr, error) {
+	start := self.pos
+	var kind ruleKind
+	switch {
+	case self.accept('~'):
+		kind = ruleGarbage
+	case self.accept('&'):
+		kind = rulePeek
+	case self.accept('!'):
+		kind = ruleNot
+	default:
+		return self.postfix()
+	}
+	expr, err := self.prefixed()
+	if err != nil {
+		return nil, err
+	}
+	return &ruleExpr{kind: kind, children: []*ruleExpr{expr}, pos: start}, nil
+}
+
+func (self *ruleParser) postfix() (*ruleExpr, error) {
+	start := self.pos
+	expr, err := self.primary()
+	if err != nil {
+		return nil, err
+	}
+	for !self.done() {
+		pos := self.pos
+		switch self.peek() {
+		case '*':
+			self.pos++
+			expr = &ruleExpr{kind: ruleRepeat, min: 0, max: UNBOUNDED, mode: POSSESSIVE, children: []*ruleExpr{expr}, pos: start}
+		case '+':
+			self.pos++
+			expr = &ruleExpr{kind: ruleRepeat, min: 1, max: UNBOUNDED, mode: POSSESSIVE, children: []*ruleExpr{expr}, pos: start}
+		case '?':
+			self.pos++
+			expr = &ruleExpr{kind: ruleMaybe, children: []*ruleExpr{expr}, pos: start}
+		case '{':
+			self.pos++
+			min, max, err := self.bounds()
+			if err != nil {
+				return nil, err
+			}
+			mode := GREEDY
+			if self.peek() == '?' {
+				self.pos++
+				mode = LAZY
+			} else if self.peek() == '+' {
+				self.pos++
+				mode = POSSESSIVE
+			}
+			expr = &ruleExpr{kind: ruleRepeat, min: min, max: max, mode: mode, children: []*ruleExpr{expr}, pos: pos}
+		default:
+			return expr, nil
+		}
+	}
+	return expr, nil
+}
+
+// Parses the "m,n}" of a{m,n}, a{m,} or a{m}.
+func (self *ruleParser) bounds() (int, int, error) {
+	min, err := self.number()
+	if err != nil {
+		return 0, 0, err
+	}
+	max := min
+	if self.accept(',') {
+		self.skipSpace()
+		if self.peek() == '}' {
+			max = UNBOUNDED
+		} else if max, err = self.number(); err != nil {
+			return 0, 0, err
+		}
+	}
+	pos := self.pos
+	if !self.accept('}') {
+		return 0, 0, self.error(pos, "expected '}'")
+	}
+	return min, max, nil
+}
+
+func (self *ruleParser) number() (int, error) {
+	self.skipSpace()
+	start := self.pos
+	for !self.done() && unicode.IsDigit(self.peek()) {
+		self.pos++
+	}
+	n, err := strconv.Atoi(string(self.text[start:self.pos]))
+	if err != nil {
+		return 0, self.error(start, "expected a number")
+	}
+	return n, nil
+}
+
+func (self *ruleParser) primary() (*ruleExpr, error) {
+	self.skipSpace()
+	start := self.pos
+	switch r := self.peek(); {
+	case r == '(':
+		self.pos++
+		expr, err := self.choice()
+		if err != nil {
+			return nil, err
+		}
+		pos := self.pos
+		if !self.accept(')') {
+			return nil, self.error(pos, "expected ')'")
+		}
+		return expr, nil
+	case r == '"' || r == '\'':
+		self.pos++
+		text, err := self.literal(r)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			return nil, self.error(start, "empty literal")
+		}
+		// A trailing i, as in "select"i, matches in any case.
+		fold := self.peek() == 'i' && (self.pos+1 >= len(self.text) || !isRuleRune(self.text[self.pos+1], false))
+		if fold {
+			self.pos++
+		}
+		return &ruleExpr{kind: ruleLiteral, text: text, fold: fold, pos: start}, nil
+	case r == '[':
+		self.pos++
+		return self.class(start)
+	case r == '<':
+		self.pos++
+		return self.capture(start)
+	case r == '.':
+		self.pos++
+		return &ruleExpr{kind: ruleClass, ranges: [][2]rune{{0, unicode.MaxRune}}, pos: start}, nil
+	case isRuleRune(r, true):
+		name, err := self.rule()
+		if err != nil {
+			return nil, err
+		}
+		return &ruleExpr{kind: ruleRef, text: name, pos: start}, nil
+	}
+	if self.done() {
+		return nil, self.error(start, "unexpected end of grammar")
+	}
+	return nil, self.error(start, "unexpected %q", self.peek())
+}
+
+// Reads one character of a literal or a class, handling escapes.
+func (self *ruleParser) char() (rune, error) {
+	start := self.pos
+	if self.done() {
+		return 0, self.error(start, "unexpected end of grammar")
+	}
+	r := self.text[self.pos]
+	self.pos++
+	if r != '\\' {
+		return r, nil
+	}
+	if self.done() {
+		return 0, self.error(start, "unexpected end of grammar")
+	}
+	r = self.text[self.pos]
+	self.pos++
+	switch r {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '0':
+		return 0, nil
+	case 'x', 'u':
+		digits := 2
+		if r == 'u' {
+			digits = 4
+		}
+		if self.pos+digits > len(self.text) {
+			return 0, self.error(start, "invalid escape")
+		}
+		n, err := strconv.ParseUint(string(self.text[self.pos:self.pos+digits]), 16, 32)
+		if err != nil {
+			return 0, self.error(start, "invalid escape")
+		}
+		self.pos += digits
+		return rune(n), nil
+	case '\\', '"', '\'', '[', ']', '-', '^':
+		return r, nil
+	}
+	return 0, self.error(start, "unknown escape \\%c", r)
+}
+
+func (self *ruleParser) literal(quote rune) (string, error) {
+	var out strings.Builder
+	start := self.pos - 1
+	for {
+		if self.done() || self.peek() == '\n' {
+			return "", self.error(start, "unterminated literal")
+		}
+		if self.peek() == quote {
+			self.pos++
+			return out.String(), nil
+		}
+		r, err := self.char()
+		if err != nil {
+			return "", err
+		}
+		out.WriteRune(r)
+	}
+}
+
+func (self *ruleParser) class(start int) (*ruleExpr, error) {
+	expr := &ruleExpr{kind: ruleClass, pos: start}
+	if self.peek() == '^' {
+		self.pos++
+		expr.negated = true
+	}
+	for {
+		if self.done() {
+			return nil, self.error(start, "unterminated class")
+		}
+		if self.peek() == ']' {
+			self.pos++
+			break
+		}
+		pos := self.pos
+		if self.peek() == '\\' && self.pos+1 < len(self.text) && self.text[self.pos+1] == 'p' {
+			ranges, err := self.category()
+			if err != nil {
+				return nil, err
+			}
+			expr.ranges = append(expr.ranges, ranges...)
+			continue
+		}
+		lo, err := self.char()
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if self.peek() == '-' && self.pos+1 < len(self.text) && self.text[self.pos+1] != ']' {
+			self.pos++
+			if hi, err = self.char(); err != nil {
+				return nil, err
+			}
+		}
+		if hi < lo {
+			return nil, self.error(pos, "invalid range %q-%q", lo, hi)
+		}
+		expr.ranges = append(expr.ranges, [2]rune{lo, hi})
+	}
+	if len(expr.ranges) == 0 {
+		return nil, self.error(start, "empty class")
+	}
+	return expr, nil
+}
+
+// Reads a \p{name} in a class.
+func (self *ruleParser) category() ([][2]rune, error) {
+	start := self.pos
+	self.pos += 2
+	if self.peek() != '{' {
+		return nil, self.error(start, "expected '{' after \\p")
+	}
+	self.pos++
+	end := self.pos
+	for end < len(self.text) && self.text[end] != '}' {
+		end++
+	}
+	if end == len(self.text) {
+		return nil, self.error(start, "unterminated \\p{")
+	}
+	name := string(self.text[self.pos:end])
+	table, ok := lookupTable(name)
+	if !ok {
+		return nil, self.error(start, "unknown Unicode category or script %q", name)
+	}
+	self.pos = end + 1
+	ranges := [][2]rune{}
+	for _, r := range tableRanges(table) {
+		ranges = append(ranges, [2]rune{r.lo, r.hi})
+	}
+	return ranges, nil
+}
+
+// Reads the rest of a <name: expr> capture or a <=name> backreference.
+func (self *ruleParser) capture(start int) (*ruleExpr, error) {
+	backref := self.accept('=')
+	name, err := self.rule()
+	if err != nil {
+		return nil, err
+	}
+	expr := &ruleExpr{kind: ruleBackref, text: name, pos: start}
+	if !backref {
+		pos := self.pos
+		if !self.accept(':') {
+			return nil, self.error(pos, "expected ':' after capture name %q", name)
+		}
+		child, err := self.choice()
+		if err != nil {
+			return nil, err
+		}
+		expr = &ruleExpr{kind: ruleCapture, text: name, children: []*ruleExpr{child}, pos: start}
+	}
+	pos := self.pos
+	if !self.accept('>') {
+		return nil, self.error(pos, "expected '>'")
+	}
+	return expr, nil
+}
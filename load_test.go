@@ -0,0 +1,105 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	rules, err := LoadRules(`
+		# Numbers may have a fractional part.
+		number = digit+ ("." digit+)? @number ;
+		space  = ~[ \t\n]+ ;
+		word   = [a-zA-Z_] [a-zA-Z0-9_]* @word ;
+		op     = "+" | '-' ;
+		main   = number space op space word eof ;
+	`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+
+	result := rules["main"].MustCompile("12.5 + x_1")
+	tokens := result.Tokens()
+	if len(tokens) != 6 || tokens[0].Name != "number" || tokens[0].Value != "12.5" ||
+		tokens[1].Name != garbage || tokens[4].Name != "word" || tokens[4].Value != "x_1" {
+		t.Errorf("Loaded rules give the wrong tokens: %v", tokens)
+	}
+	if rules["main"].Match("12 $ x") {
+		t.Error("Loaded rules match too much")
+	}
+}
+
+func TestLoadRulesRepetition(t *testing.T) {
+	rules, err := LoadRules(`
+		two    = "a"{2} ;
+		some   = "a"{1,3} ;
+		many   = "a"{2,} ;
+		maybe  = "a"? "b" ;
+		first  = "a" / "ab" ;
+	`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+
+	if len(rules["two"].Compile("aaa")) != 1 || rules["two"].Match("a") {
+		t.Error("Loaded {m} repeats the wrong number of times")
+	}
+	if len(rules["some"].Compile("aaaa")) != 3 {
+		t.Error("Loaded {m,n} repeats the wrong number of times")
+	}
+	if len(rules["many"].Compile("aaaa")) != 3 || rules["many"].Match("a") {
+		t.Error("Loaded {m,} repeats the wrong number of times")
+	}
+	if !rules["maybe"].Match("b") || !rules["maybe"].Match("ab") {
+		t.Error("Loaded ? is not optional")
+	}
+	if results := rules["first"].Compile("ab"); len(results) != 1 || results[0].left_over != "b" {
+		t.Error("Loaded / does not stop at the first choice that matches")
+	}
+	if !rules["first"].Match("a") || rules["first"].Match("b") {
+		t.Error("Loaded / does not try its choices in order")
+	}
+}
+
+func TestLoadRulesNullableRepetition(t *testing.T) {
+	rules, err := LoadRules(`
+		star = ("a"?)* "b" ;
+		plus = ("a" | "c"?)+ "b" ;
+	`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if _, err := rules["star"].compileAll("aab"); err != nil {
+		t.Error("A star over an optional does not match")
+	}
+	if _, err := rules["plus"].compileAll("acab"); err != nil {
+		t.Error("A plus over an optional does not match")
+	}
+	ebnf := rules["star"].EBNF()
+	if ebnf != "main = \"a\"?* \"b\" ;\n" {
+		t.Errorf("EBNF writes %s", ebnf)
+	}
+	if again, err := LoadRules(ebnf); err != nil || !again["main"].Match("aab") {
+		t.Errorf("The EBNF does not load back: %v", err)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	errors := map[string]string{
+		"a = \"a\"":                           `1:8: expected ';' at the end of rule "a"`,
+		"a = b ;":                             `1:5: undefined rule "b"`,
+		"a = \"x\" ;\nb = c ;\nc = \"(\" b ;": `3:9: rule "b" refers to itself`,
+		"a = \"x\" |\n  ;":                    `2:3: expected an expression`,
+		"a = [z-a] ;":                         `1:6: invalid range 'z'-'a'`,
+		"a = \"a\" | \"b\" / \"c\" ;":         `1:15: '|' and '/' can't be mixed without parentheses`,
+		"a = \"abc ;":                         `1:5: unterminated literal`,
+		"a = \"a\"{3,1} ;":                    `1:8: invalid repetition {3,1}`,
+	}
+	for grammar, expected := range errors {
+		_, err := LoadRules(grammar)
+		if err == nil {
+			t.Errorf("LoadRules accepts %q", grammar)
+		} else if err.Error() != expected {
+			t.Errorf("LoadRules gives %q instead of %q", err, expected)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package abstract
+
+//// Lookahead.
+
+// Peek matches where lexer would match, without consuming any text or
+// producing any tokens.
+func Peek(lexer *Lexer) *Lexer {
+	b := base()
+	b.action = PEEK
+	b.children = []*Lexer{lexer}
+	return b
+}
+
+// Not matches where lexer would not match, without consuming any text or
+// producing any tokens. And(Lex("if"), Not(Alphanumeric)) doesn't match
+// the start of "iffy".
+func Not(lexer *Lexer) *Lexer {
+	b := base()
+	b.action = NOT
+	b.children = []*Lexer{lexer}
+	return b
+}
+
+// What the lookahead captures is forgotten with the rest of its match.
+func (self *Lexer) compileLookahead(str string, captures *binding) []*Result {
+	matched := len(self.children[0].compile(str, captures)) > 0
+	if matched == (self.action == PEEK) {
+		return singleResult([]*Token{}, str, captures)
+	}
+	return []*Result{}
+}
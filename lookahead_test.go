@@ -0,0 +1,42 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestLookahead(t *testing.T) {
+	ident := Munch(Alpha).Alias("ident")
+	keyword := And(Lex("if"), Not(Alphanumeric))
+	lexer := Munch(OneOf(keyword, ident, Lex(" ").Garbage()))
+
+	result := lexer.MustCompile("if iffy")
+	tokens := result.Tokens()
+	if len(tokens) != 3 || tokens[0].Name != "if" || tokens[2].Name != "ident" || tokens[2].Value != "iffy" {
+		t.Errorf("Not does not keep keywords out of identifiers: %v", tokens)
+	}
+
+	call := And(ident, Peek(Lex("(")))
+	if !call.Match("f(x)") || call.Match("f x") {
+		t.Error("Peek is wrong")
+	}
+	result = call.Compile("f(x)")[0]
+	if len(result.Tokens()) != 1 || result.left_over != "(x)" {
+		t.Error("Peek consumes text or produces tokens")
+	}
+	if !And(Lex("a"), Not(Any())).Match("a") {
+		t.Error("Not does not match at the end of the input")
+	}
+}
+
+func TestLookaheadRules(t *testing.T) {
+	rules, err := LoadRules(`keyword = "if" !alphanumeric ; call = alpha+ &"(" ;`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if rules["keyword"].Match("iffy") || !rules["keyword"].Match("if x") || !rules["call"].Match("f()") {
+		t.Error("&a and !a don't load")
+	}
+	if rules["keyword"].EBNF() != "main = \"if\" !alphanumeric ;\nalphanumeric = [0-9A-Za-z] ;\n" {
+		t.Errorf("EBNF writes %s", rules["keyword"].EBNF())
+	}
+}
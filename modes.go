@@ -0,0 +1,168 @@
+package abstract
+
+import (
+	"fmt"
+)
+
+//// Lexer Modes.
+//
+// Modes lexes text where what a token looks like depends on where it is,
+// like flex's start conditions. Interpolated strings need two modes:
+//
+//	modes := NewModes("expr")
+//	modes.Mode("expr", FirstOf(Push(Lex("\""), "string"), Pop(Lex("}")), Alpha, Lex("+")))
+//	modes.Mode("string", FirstOf(Pop(Lex("\"")), Push(Lex("${"), "expr"), Any()))
+//
+// The tokens record every change of mode as a "push" token, whose value is
+// the mode entered, and a "pop" token, whose value is the mode left, so
+// Between("push", "pop") groups the text lexed in each mode.
+
+type Modes struct {
+	start string
+	modes map[string]*Lexer
+}
+
+// NewModes makes a set of modes that starts lexing in the start mode.
+func NewModes(start string) *Modes {
+	return &Modes{start: start, modes: map[string]*Lexer{}}
+}
+
+// Mode sets the lexer used while name is the current mode. It panics if
+// the lexer has a Push or Pop inside an alias or Garbage, which would turn
+// the change of mode into part of their token.
+func (self *Modes) Mode(name string, lexer *Lexer) *Modes {
+	if hidesModeChange(lexer, map[*Lexer]bool{}) {
+		panic("Push and Pop can't be inside an Alias or Garbage in a Mode.")
+	}
+	self.modes[name] = lexer
+	return self
+}
+
+// Whether lexer has an alias or Garbage with a Push or Pop inside it.
+func hidesModeChange(lexer *Lexer, visited map[*Lexer]bool) bool {
+	if visited[lexer] || lexer.action == PEEK || lexer.action == NOT {
+		return false
+	}
+	visited[lexer] = true
+	if lexer.token != "" && len(lexer.children) > 0 {
+		return changesMode(lexer, map[*Lexer]bool{})
+	}
+	for _, child := range lexer.children {
+		if hidesModeChange(child, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether lexer can give a push or pop token. Lookaheads keep no tokens.
+func changesMode(lexer *Lexer, visited map[*Lexer]bool) bool {
+	if visited[lexer] || lexer.action == PEEK || lexer.action == NOT {
+		return false
+	}
+	visited[lexer] = true
+	if lexer.action == PUSH || lexer.action == POP {
+		return true
+	}
+	for _, child := range lexer.children {
+		if changesMode(child, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Push matches lexer, then enters mode, which stays current until a Pop.
+// The "push" token comes after the tokens of lexer. An alias or Garbage
+// around a Push or Pop makes it part of their token, so Modes doesn't allow
+// that.
+func Push(lexer *Lexer, mode string) *Lexer {
+	b := base()
+	b.action = PUSH
+	b.name = mode
+	b.children = append(b.children, lexer)
+	return b
+}
+
+// Pop matches lexer, after going back to the mode that was current before
+// the last Push. The "pop" token comes before the tokens of lexer.
+func Pop(lexer *Lexer) *Lexer {
+	b := base()
+	b.action = POP
+	b.children = append(b.children, lexer)
+	return b
+}
+
+// Outside of Modes, Push and Pop only add their tokens.
+func (self *Lexer) compileMode(str string, captures *binding) []*Result {
+	results := self.children[0].compile(str, captures)
+	for _, result := range results {
+		if self.action == PUSH {
+			result.tokens = append(result.tokens, virtualToken("push", self.name))
+		} else {
+			result.tokens = append([]*Token{virtualToken("pop", "")}, result.tokens...)
+		}
+	}
+	return results
+}
+
+// Compile lexes all of str, one token of the current mode at a time. Where
+// the lexer of a mode has more than one result, the longest one is taken.
+// It is an error for no result to go further, for a Pop to leave the start
+// mode and for str to end in another mode.
+func (self *Modes) Compile(str string) (*Result, error) {
+	stack := []string{self.start}
+	out := &Result{tokens: []*Token{}, left_over: str}
+
+	for out.left_over != "" {
+		mode := stack[len(stack)-1]
+		lexer, ok := self.modes[mode]
+		if !ok {
+			return nil, fmt.Errorf("unknown mode %q", mode)
+		}
+
+		var longest *Result
+		for _, result := range lexer.compile(out.left_over, out.captures) {
+			if len(result.left_over) < len(out.left_over) &&
+				(longest == nil || len(result.left_over) < len(longest.left_over)) {
+				longest = result
+			}
+		}
+		if longest == nil {
+			offset := len(str) - len(out.left_over)
+			return nil, fmt.Errorf("nothing in mode %q matches at offset %d", mode, offset)
+		}
+
+		for _, tok := range longest.tokens {
+			if tok.raw != "" {
+				continue // Lex("push") is not a change of mode.
+			}
+			switch tok.Name {
+			case "push":
+				stack = append(stack, tok.Value)
+			case "pop":
+				if len(stack) == 1 {
+					offset := len(str) - len(out.left_over)
+					return nil, fmt.Errorf("pop from the start mode %q at offset %d", self.start, offset)
+				}
+				tok.Value = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		}
+		out = extend(out, longest)
+	}
+
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("the input ends in mode %q", stack[len(stack)-1])
+	}
+	return out, nil
+}
+
+// Like Compile, but panics instead of returning an error.
+func (self *Modes) MustCompile(str string) *Result {
+	result, err := self.Compile(str)
+	if err != nil {
+		panic(err.Error())
+	}
+	return result
+}
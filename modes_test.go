@@ -0,0 +1,109 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+func interpolation() *Modes {
+	text := Munch(And(Not(Lex("\"")), Not(Lex("${")), Any())).Alias("text")
+	modes := NewModes("expr")
+	modes.Mode("expr", FirstOf(
+		Push(Lex("\""), "string"),
+		Pop(Lex("}")),
+		Munch(Alpha).Alias("name"),
+		Munch(Digit).Alias("number"),
+		Lex("+"),
+		Munch(Space).Garbage(),
+	))
+	modes.Mode("string", FirstOf(Pop(Lex("\"")), Push(Lex("${"), "expr"), text))
+	return modes
+}
+
+func TestModes(t *testing.T) {
+	input := `"hello ${name + 1} world"`
+	result, err := interpolation().Compile(input)
+	if err != nil {
+		t.Fatalf("Modes fail: %s", err)
+	}
+
+	names := []string{}
+	for _, tok := range result.Tokens() {
+		if tok.Name != garbage {
+			names = append(names, tok.String())
+		}
+	}
+	expected := `":" push:string text:hello  ${:${ push:expr name:name +:+ number:1 pop:expr }:} text: world pop:string ":"`
+	if strings.Join(names, " ") != expected {
+		t.Errorf("The tokens are %s", strings.Join(names, " "))
+	}
+
+	tree := AbstractParent(result.Tokens())
+	if tree.Source() != input {
+		t.Errorf("The source is %q", tree.Source())
+	}
+	tree.Filter(garbage)
+	tree.Between("push", "pop")
+	if len(tree.Children) != 3 || tree.Children[1].Token.Name != "pushpop" {
+		t.Fatalf("Between does not group the string: %s", tree)
+	}
+	inner := tree.Children[1]
+	if len(inner.Children) != 5 || inner.Children[2].Token.Name != "pushpop" {
+		t.Errorf("Between does not group the expression: %s", inner)
+	}
+}
+
+func TestModesNesting(t *testing.T) {
+	modes := interpolation()
+	if _, err := modes.Compile(`"a ${"b ${c}"} d"`); err != nil {
+		t.Errorf("Nested modes fail: %s", err)
+	}
+	if _, err := modes.Compile(`"a ${b`); err == nil || !strings.Contains(err.Error(), `mode "expr"`) {
+		t.Errorf("An unclosed expression gives %v", err)
+	}
+	if _, err := modes.Compile(`a}`); err == nil {
+		t.Error("Popping the start mode is not an error")
+	}
+	if _, err := modes.Compile(`a - b`); err == nil || !strings.Contains(err.Error(), "offset 2") {
+		t.Errorf("An unknown character gives %v", err)
+	}
+	if _, err := NewModes("none").Compile("a"); err == nil {
+		t.Error("An unknown mode is not an error")
+	}
+}
+
+func TestPushOutsideModes(t *testing.T) {
+	tokens := And(Push(Lex("("), "inner"), Pop(Lex(")"))).MustCompile("()").Tokens()
+	if len(tokens) != 4 || tokens[1].String() != "push:inner" || tokens[2].Name != "pop" {
+		t.Errorf("Push and Pop give %v", tokens)
+	}
+	if !strings.Contains(Push(a, "inner").Diagrams()[0].SVG, "push inner") {
+		t.Error("The diagram does not show the push")
+	}
+}
+
+func TestPushValidates(t *testing.T) {
+	lexer := Munch(OneOf(Push(Lex("("), "inner"), Pop(Lex(")")), a))
+	if err := NewGrammar(lexer).Between("push", "pop").Validate(); err != nil {
+		t.Errorf("Validate does not know Push and Pop: %s", err)
+	}
+}
+
+func TestPushInAlias(t *testing.T) {
+	// The push token stands for no text, so it adds nothing to the alias.
+	tokens := And(Push(Lex("\""), "string")).Alias("q").MustCompile("\"").Tokens()
+	if len(tokens) != 1 || tokens[0].String() != "q:\"" {
+		t.Errorf("The alias gives %v", tokens)
+	}
+
+	for _, hidden := range []*Lexer{Push(Lex("\""), "string").Alias("q"), Pop(Lex("}")).Garbage()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Mode takes a Push or Pop inside an alias or Garbage")
+				}
+			}()
+			NewModes("expr").Mode("expr", FirstOf(Lex("+"), hidden))
+		}()
+	}
+}
@@ -0,0 +1,165 @@
+package abstract
+
+import (
+	"fmt"
+	"strings"
+)
+
+//// Indentation.
+//
+// Offside turns the indentation of each line into tokens, the way Python
+// does, so blocks can be built with Between("indent", "dedent"):
+//
+//	if x:          if : x newline indent
+//	    y          y newline
+//	z              dedent z newline
+
+type TabPolicy int
+
+const (
+	TABS_EXACT     TabPolicy = iota // Lines must start with the same tabs and spaces to line up.
+	TABS_EXPAND                     // Tabs move to the next multiple of TabWidth.
+	TABS_FORBIDDEN                  // Tabs in indentation are errors.
+)
+
+type Offside struct {
+	Tabs     TabPolicy
+	TabWidth int      // Only used for TABS_EXPAND, 8 if zero.
+	Skip     []string // Tokens that don't start a line, like comments.
+}
+
+// An IndentError is where the indentation doesn't line up.
+type IndentError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (self *IndentError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", self.Line, self.Column, self.Message)
+}
+
+// A token that stands for no text in the input.
+func virtualToken(name string, value string) *Token {
+	return &Token{Name: name, Value: value, raw: "", has_raw: true}
+}
+
+func isLayout(str string) bool {
+	return strings.Trim(str, " \t\r\n\f") == ""
+}
+
+// Rewrite adds a "newline" token after each line that holds more than
+// layout and skipped tokens, an "indent" token before the first token of
+// a line indented further than the line before, and a "dedent" token for
+// each indentation level a line goes back out of. The tokens keep the
+// whitespace they were lexed with; the new tokens stand for no text, so
+// the tree's Source is unchanged. Every indent is closed at the end.
+func (self Offside) Rewrite(tokens []*Token) ([]*Token, error) {
+	out := []*Token{}
+	levels := []string{""}
+	line, column := 1, 1
+	indentation, line_start, line_has_token := "", true, false
+
+	skip := map[string]bool{}
+	for _, name := range self.Skip {
+		skip[name] = true
+	}
+
+	for _, tok := range tokens {
+		source := tok.source()
+		if isLayout(source) || skip[tok.Name] {
+			if line_has_token && strings.ContainsRune(source, '\n') {
+				out = append(out, virtualToken("newline", ""))
+				line_has_token = false
+			}
+			out = append(out, tok)
+			for _, r := range source {
+				switch {
+				case r == '\n':
+					line, column = line+1, 1
+					indentation, line_start = "", true
+					continue
+				case line_start && (r == ' ' || r == '\t'):
+					indentation += string(r)
+				default:
+					line_start = false
+				}
+				column++
+			}
+			continue
+		}
+
+		if line_start {
+			expanded, err := self.expand(indentation, line)
+			if err != nil {
+				return nil, err
+			}
+			top := levels[len(levels)-1]
+			switch {
+			case expanded == top:
+			case strings.HasPrefix(expanded, top):
+				levels = append(levels, expanded)
+				out = append(out, virtualToken("indent", indentation))
+			case len(expanded) > len(top):
+				return nil, &IndentError{line, column, "the indentation mixes tabs and spaces unlike the line before"}
+			default:
+				for len(levels) > 1 && len(levels[len(levels)-1]) > len(expanded) {
+					levels = levels[:len(levels)-1]
+					out = append(out, virtualToken("dedent", ""))
+				}
+				if levels[len(levels)-1] != expanded {
+					return nil, &IndentError{line, column, "the indentation does not match any outer level"}
+				}
+			}
+			line_start = false
+		}
+		line_has_token = true
+
+		out = append(out, tok)
+		for _, r := range source {
+			if r == '\n' {
+				line, column = line+1, 1
+			} else {
+				column++
+			}
+		}
+		if strings.HasSuffix(source, "\n") {
+			out = append(out, virtualToken("newline", ""))
+			indentation, line_start, line_has_token = "", true, false
+		}
+	}
+
+	if line_has_token {
+		out = append(out, virtualToken("newline", ""))
+	}
+	for len(levels) > 1 {
+		levels = levels[:len(levels)-1]
+		out = append(out, virtualToken("dedent", ""))
+	}
+	return out, nil
+}
+
+// The indentation as the policy compares it.
+func (self Offside) expand(indentation string, line int) (string, error) {
+	switch self.Tabs {
+	case TABS_FORBIDDEN:
+		if i := strings.IndexByte(indentation, '\t'); i >= 0 {
+			return "", &IndentError{line, i + 1, "tabs are not allowed in indentation"}
+		}
+	case TABS_EXPAND:
+		width := self.TabWidth
+		if width == 0 {
+			width = 8
+		}
+		column := 0
+		for _, r := range indentation {
+			if r == '\t' {
+				column += width - column%width
+			} else {
+				column++
+			}
+		}
+		return strings.Repeat(" ", column), nil
+	}
+	return indentation, nil
+}
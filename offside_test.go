@@ -0,0 +1,73 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+// Lexes Python-like lines: words, colons, comments and whitespace.
+func layoutTokens(str string) []*Token {
+	word := Munch(Alphanumeric).Alias("word")
+	comment := And(Lex("#"), Until(OneOf(Lex("\n"), Eof))).Alias("comment")
+	space := Munch(Union(Lex(" "), Lex("\t"), Lex("\n"))).Garbage()
+	return Maybe(Munch(OneOf(word, Lex(":"), comment, space))).MustCompile(str).Tokens()
+}
+
+func tokenNames(tokens []*Token) string {
+	names := []string{}
+	for _, tok := range tokens {
+		if tok.Name != garbage {
+			names = append(names, tok.Name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func TestOffside(t *testing.T) {
+	input := "if x:\n    y\n\n    # note\n    if z:\n        w\nv\n"
+	tokens, err := Offside{Skip: []string{"comment"}}.Rewrite(layoutTokens(input))
+	if err != nil {
+		t.Fatalf("Rewrite fails: %s", err)
+	}
+	expected := "word word : newline indent word newline comment word word : newline indent word newline dedent dedent word newline"
+	if tokenNames(tokens) != expected {
+		t.Errorf("Rewrite gives %s", tokenNames(tokens))
+	}
+
+	tree := AbstractParent(tokens)
+	if tree.Source() != input {
+		t.Errorf("The new tokens change the source to %q", tree.Source())
+	}
+	tree.Filter(garbage)
+	tree.Between("indent", "dedent")
+	if len(tree.Children) != 7 || tree.Children[4].Token.Name != "indentdedent" || tree.Children[4].Children[7].Token.Name != "indentdedent" {
+		t.Errorf("Between does not build blocks from the indentation: %d children", len(tree.Children))
+	}
+
+	tokens, _ = Offside{}.Rewrite(layoutTokens("a:\n  b"))
+	if tokenNames(tokens) != "word : newline indent word newline dedent" {
+		t.Errorf("Rewrite does not close blocks at the end: %s", tokenNames(tokens))
+	}
+}
+
+func TestOffsideErrors(t *testing.T) {
+	errors := map[string]string{
+		"a\n    b\n  c": "3:3: the indentation does not match any outer level",
+		"a\n\tb\n    c": "3:5: the indentation mixes tabs and spaces unlike the line before",
+	}
+	for input, expected := range errors {
+		_, err := Offside{}.Rewrite(layoutTokens(input))
+		if err == nil || err.Error() != expected {
+			t.Errorf("Rewrite of %q gives %v instead of %s", input, err, expected)
+		}
+	}
+
+	_, err := Offside{Tabs: TABS_FORBIDDEN}.Rewrite(layoutTokens("a\n\tb"))
+	if err == nil || err.Error() != "2:1: tabs are not allowed in indentation" {
+		t.Errorf("TABS_FORBIDDEN gives %v", err)
+	}
+	tokens, err := Offside{Tabs: TABS_EXPAND, TabWidth: 4}.Rewrite(layoutTokens("a\n\tb\n    c"))
+	if err != nil || tokenNames(tokens) != "word newline indent word newline word newline dedent" {
+		t.Errorf("TABS_EXPAND gives %s, %v", tokenNames(tokens), err)
+	}
+}
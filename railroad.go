@@ -0,0 +1,351 @@
+package abstract
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+//// Railroad Diagrams.
+
+// A Diagram is a standalone SVG railroad diagram of one rule.
+type Diagram struct {
+	Name string
+	SVG  string
+}
+
+// Diagrams draws a railroad diagram for the lexer and for each of the rules
+// EBNF would give it: its aliases, the predefined lexers it uses, and the
+// lexers it uses in several places. These appear as boxes in the other
+// diagrams, linking to their File. The lexer's own diagram comes first.
+func (self *Lexer) Diagrams() []*Diagram {
+	writer := newGrammarWriter(self)
+	diagrams := []*Diagram{}
+	for i := 0; i < len(writer.order); i++ {
+		lexer := writer.order[i]
+		name := writer.names[lexer]
+		var track *track
+		if lexer.isAlias() {
+			track = writer.track(lexer.children[0])
+		} else {
+			track = writer.inlineTrack(lexer)
+		}
+		diagrams = append(diagrams, &Diagram{name, renderDiagram(name, track)})
+	}
+	return diagrams
+}
+
+// File is the name of the file WriteDiagrams writes the diagram to: its
+// name, with anything but ASCII letters, digits, '_' and '-' written as
+// "~" and two hex digits per byte, then ".svg". It is safe in a URL too.
+func (self *Diagram) File() string {
+	return diagramFile(self.Name)
+}
+
+func diagramFile(name string) string {
+	var out strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			out.WriteByte(c)
+		} else {
+			fmt.Fprintf(&out, "~%02X", c)
+		}
+	}
+	out.WriteString(".svg")
+	return out.String()
+}
+
+// DiagramIndex is an HTML page showing the diagrams, each linking to its own file.
+func DiagramIndex(diagrams []*Diagram) string {
+	var out bytes.Buffer
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Grammar</title>\n</head>\n<body>\n")
+	for _, diagram := range diagrams {
+		name, file := html.EscapeString(diagram.Name), diagram.File()
+		fmt.Fprintf(&out, "<h2 id=\"%s\"><a href=\"%s\">%s</a></h2>\n", name, file, name)
+		fmt.Fprintf(&out, "<p><img src=\"%s\" alt=\"%s\"></p>\n", file, name)
+	}
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+// WriteDiagrams writes the File of each of the lexer's Diagrams and an
+// "index.html" showing them all into dir.
+func (self *Lexer) WriteDiagrams(dir string) error {
+	diagrams := self.Diagrams()
+	for _, diagram := range diagrams {
+		if err := os.WriteFile(filepath.Join(dir, diagram.File()), []byte(diagram.SVG), 0644); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, "index.html"), []byte(DiagramIndex(diagrams)), 0644)
+}
+
+// A piece of a diagram. The track enters on the left and leaves on the
+// right at height zero; up and down are how far it reaches either way.
+type track struct {
+	width int
+	up    int
+	down  int
+	draw  func(out *bytes.Buffer, x int, y int)
+}
+
+const (
+	boxHeight = 22
+	charWidth = 8
+	gap       = 10 // Between the pieces of a sequence and the lines of a choice.
+	arc       = 10 // The radius of the curves.
+)
+
+func railLine(out *bytes.Buffer, x1 int, y1 int, x2 int, y2 int) {
+	if x1 != x2 || y1 != y2 {
+		fmt.Fprintf(out, "<path d=\"M%d %dL%d %d\"/>\n", x1, y1, x2, y2)
+	}
+}
+
+func textWidth(text string) int {
+	return utf8.RuneCountInString(text)*charWidth + 2*gap
+}
+
+func railTerminal(text string) *track {
+	width := textWidth(text)
+	return &track{width, boxHeight / 2, boxHeight / 2, func(out *bytes.Buffer, x int, y int) {
+		fmt.Fprintf(out, "<rect class=\"terminal\" x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"%d\"/>\n",
+			x, y-boxHeight/2, width, boxHeight, boxHeight/2)
+		fmt.Fprintf(out, "<text x=\"%d\" y=\"%d\">%s</text>\n", x+width/2, y+4, html.EscapeString(text))
+	}}
+}
+
+func railNonterminal(name string) *track {
+	width := textWidth(name)
+	return &track{width, boxHeight / 2, boxHeight / 2, func(out *bytes.Buffer, x int, y int) {
+		fmt.Fprintf(out, "<a href=\"%s\">\n", diagramFile(name))
+		fmt.Fprintf(out, "<rect class=\"nonterminal\" x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"/>\n",
+			x, y-boxHeight/2, width, boxHeight)
+		fmt.Fprintf(out, "<text x=\"%d\" y=\"%d\">%s</text>\n</a>\n", x+width/2, y+4, html.EscapeString(name))
+	}}
+}
+
+func railSkip() *track {
+	return &track{0, 0, 0, func(out *bytes.Buffer, x int, y int) {}}
+}
+
+func railSequence(items ...*track) *track {
+	seq := &track{}
+	for i, item := range items {
+		if i > 0 {
+			seq.width += gap
+		}
+		seq.width += item.width
+		seq.up = max(seq.up, item.up)
+		seq.down = max(seq.down, item.down)
+	}
+	seq.draw = func(out *bytes.Buffer, x int, y int) {
+		for i, item := range items {
+			if i > 0 {
+				railLine(out, x, y, x+gap, y)
+				x += gap
+			}
+			item.draw(out, x, y)
+			x += item.width
+		}
+	}
+	return seq
+}
+
+// The first item stays on the line, the others branch off below it.
+func railChoice(items ...*track) *track {
+	inner := 0
+	for _, item := range items {
+		inner = max(inner, item.width)
+	}
+	c := &track{width: inner + 4*arc, up: items[0].up, down: items[0].down}
+	offsets := []int{0}
+	for i, item := range items[1:] {
+		offset := c.down + gap + item.up
+		if i == 0 {
+			offset = max(offset, 2*arc)
+		}
+		offsets = append(offsets, offset)
+		c.down = offset + item.down
+	}
+	c.draw = func(out *bytes.Buffer, x int, y int) {
+		left, right := x+2*arc, x+c.width-2*arc
+		for i, item := range items {
+			offset := offsets[i]
+			if i == 0 {
+				railLine(out, x, y, left, y)
+				railLine(out, right, y, x+c.width, y)
+			} else {
+				fmt.Fprintf(out, "<path d=\"M%d %dq%d 0 %d %dV%dq0 %d %d %d\"/>\n",
+					x, y, arc, arc, arc, y+offset-arc, arc, arc, arc)
+				fmt.Fprintf(out, "<path d=\"M%d %dq%d 0 %d %dV%dq0 %d %d %d\"/>\n",
+					right, y+offset, arc, arc, -arc, y+arc, -arc, arc, -arc)
+			}
+			item.draw(out, left, y+offset)
+			railLine(out, left+item.width, y+offset, right, y+offset)
+		}
+	}
+	return c
+}
+
+// The item stays on the line, the way back to its start runs below it.
+func railLoop(item *track, label string) *track {
+	l := &track{width: item.width + 4*arc, up: item.up, down: item.down + gap + arc}
+	if label != "" {
+		l.down += 14
+		l.width = max(l.width, textWidth(label))
+	}
+	l.draw = func(out *bytes.Buffer, x int, y int) {
+		left := x + (l.width-item.width)/2
+		right := left + item.width
+		railLine(out, x, y, left, y)
+		item.draw(out, left, y)
+		railLine(out, right, y, x+l.width, y)
+		bottom := y + item.down + gap
+		fmt.Fprintf(out, "<path d=\"M%d %dq%d 0 %d %dV%dq0 %d %d %dH%dq%d 0 %d %dV%dq0 %d %d %d\"/>\n",
+			right, y, arc, arc, arc, bottom-arc, arc, -arc, arc, left, -arc, -arc, -arc, y+arc, -arc, arc, -arc)
+		if label != "" {
+			fmt.Fprintf(out, "<text class=\"label\" x=\"%d\" y=\"%d\">%s</text>\n", x+l.width/2, bottom+14, html.EscapeString(label))
+		}
+	}
+	return l
+}
+
+// Draws a dashed box around the item, labelled above.
+func railFrame(item *track, label string) *track {
+	f := &track{width: max(item.width, textWidth(label)) + 2*gap, up: item.up + gap + 14, down: item.down + gap}
+	f.draw = func(out *bytes.Buffer, x int, y int) {
+		fmt.Fprintf(out, "<rect class=\"frame\" x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"/>\n",
+			x, y-f.up, f.width, f.up+f.down)
+		fmt.Fprintf(out, "<text class=\"label\" x=\"%d\" y=\"%d\">%s</text>\n", x+f.width/2, y-f.up+14, html.EscapeString(label))
+		left := x + (f.width-item.width)/2
+		railLine(out, x, y, left, y)
+		item.draw(out, left, y)
+		railLine(out, left+item.width, y, x+f.width, y)
+	}
+	return f
+}
+
+func (self *grammarWriter) track(lexer *Lexer) *track {
+	if name, ok := self.rule(lexer); ok {
+		return railNonterminal(name)
+	}
+	return self.inlineTrack(lexer)
+}
+
+func (self *grammarWriter) inlineTrack(lexer *Lexer) *track {
+	if lexer.action == CLASS {
+		return railTerminal(lexer.class.String())
+	}
+	if lexer.action == FOLD {
+		return railTerminal(quoteLiteral(lexer.token) + "i")
+	}
+	if lexer.action == STRING {
+		return self.inlineTrack(lexer.stringExpansion())
+	}
+	if lexer.action == UNTIL {
+		return self.inlineTrack(lexer.untilExpansion())
+	}
+	if lexer.action == BACKREF {
+		return railTerminal("same as " + lexer.name)
+	}
+	if len(lexer.children) == 0 {
+		return railTerminal(quoteLiteral(lexer.token))
+	}
+
+	if lexer.action == SEPBY {
+		return self.inlineTrack(lexer.sepByExpansion())
+	}
+	if lexer.action == SKIP {
+		skipped := railChoice(railSkip(), railFrame(railLoop(self.track(lexer.children[0]), "munch"), "skip"))
+		if len(lexer.children) == 1 {
+			return skipped
+		}
+		return railSequence(skipped, self.track(lexer.children[1]))
+	}
+
+	tracks := make([]*track, len(lexer.children))
+	for i, child := range lexer.children {
+		tracks[i] = self.track(child)
+	}
+	child := tracks[0]
+	if lexer.token == garbage {
+		return railFrame(child, "garbage")
+	}
+
+	switch lexer.action {
+	case XOR:
+		return railChoice(tracks...)
+	case FIRST:
+		return railFrame(railChoice(tracks...), "first of")
+	case CAPTURE:
+		return railFrame(child, "capture "+lexer.name)
+	case PUSH:
+		return railFrame(child, "push "+lexer.name)
+	case POP:
+		return railFrame(child, "pop")
+	case KEYWORDS:
+		return railFrame(child, "or a keyword")
+	case PEEK:
+		return railFrame(child, "followed by")
+	case NOT:
+		return railFrame(child, "not followed by")
+	case MUNCH:
+		return railLoop(child, "munch")
+	case MANY:
+		return railLoop(child, "many")
+	case NMANY:
+		return self.inlineTrack(lexer.nmanyExpansion())
+	case REPEAT:
+		label := fmt.Sprintf("%d to %d", lexer.from, lexer.to)
+		if lexer.to == UNBOUNDED {
+			label = fmt.Sprintf("%d or more", lexer.from)
+		}
+		if lexer.mode == POSSESSIVE {
+			label += ", munch"
+		}
+		if lexer.from == 0 {
+			return railChoice(railSkip(), railLoop(child, label))
+		}
+		return railLoop(child, label)
+	case OR:
+		return railChoice(railSkip(), child)
+	}
+	return railSequence(tracks...)
+}
+
+func renderDiagram(name string, body *track) string {
+	const margin = 20
+	const end = 10 // The bars at either end.
+	width := body.width + 2*margin + 2*end
+	height := body.up + body.down + 2*margin
+	y := margin + body.up
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		width, height, width, height)
+	fmt.Fprintf(&out, "<title>%s</title>\n", html.EscapeString(name))
+	out.WriteString(`<style>
+path { fill: none; stroke: black; stroke-width: 2; }
+rect { fill: #ffc; stroke: black; stroke-width: 2; }
+rect.nonterminal { fill: #cef; }
+rect.frame { fill: none; stroke: gray; stroke-width: 1; stroke-dasharray: 4 3; }
+text { font: 14px monospace; text-anchor: middle; }
+text.label { font-size: 11px; fill: gray; }
+</style>
+`)
+	x := margin
+	fmt.Fprintf(&out, "<path d=\"M%d %dv%dM%d %dv%d\"/>\n", x, y-end, 2*end, x+end/2, y-end, 2*end)
+	railLine(&out, x, y, x+end, y)
+	body.draw(&out, x+end, y)
+	x += end + body.width
+	railLine(&out, x, y, x+end, y)
+	fmt.Fprintf(&out, "<path d=\"M%d %dv%dM%d %dv%d\"/>\n", x+end/2, y-end, 2*end, x+end, y-end, 2*end)
+	out.WriteString("</svg>\n")
+	return out.String()
+}
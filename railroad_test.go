@@ -0,0 +1,91 @@
+package abstract
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func wellFormed(document string) error {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestDiagrams(t *testing.T) {
+	number := And(Munch(Digit), Maybe(And(Lex("."), Munch(Digit)))).Alias("number")
+	spaces := Maybe(Munch(Lex(" "))).Garbage()
+	lexer := And(number, Many(And(spaces, OneOfString("+", "-", "<&>"), spaces, number)), NMany(Lex(";"), 2))
+
+	diagrams := lexer.Diagrams()
+	names := []string{}
+	for _, diagram := range diagrams {
+		names = append(names, diagram.Name)
+		if err := wellFormed(diagram.SVG); err != nil {
+			t.Errorf("Diagram %s is not well-formed: %s", diagram.Name, err)
+		}
+	}
+	if strings.Join(names, " ") != "main number rule1 digit" {
+		t.Errorf("Diagrams draws the rules %v", names)
+	}
+
+	main := diagrams[0].SVG
+	for _, part := range []string{`<a href="number.svg">`, `<a href="rule1.svg">`, `&lt;&amp;&gt;`, ">many<", ">1 to 2<"} {
+		if !strings.Contains(main, part) {
+			t.Errorf("The main diagram does not contain %s", part)
+		}
+	}
+	if !strings.Contains(diagrams[2].SVG, ">garbage<") {
+		t.Error("The diagram of the spaces does not show they are garbage")
+	}
+	if !strings.Contains(diagrams[1].SVG, `<a href="digit.svg">`) || !strings.Contains(diagrams[1].SVG, ">munch<") {
+		t.Error("The number diagram does not link to digit")
+	}
+}
+
+func TestDiagramFiles(t *testing.T) {
+	dir := t.TempDir()
+	lexer := And(Lex("a").Alias("../escaped"), Lex("b").Alias("a/b"), Lex("c").Alias("a~2Fb"))
+	if err := lexer.WriteDiagrams(dir); err != nil {
+		t.Fatalf("WriteDiagrams fails: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "escaped.svg")); err == nil {
+		t.Error("WriteDiagrams writes outside its directory")
+	}
+	files := []string{"~2E~2E~2Fescaped.svg", "a~2Fb.svg", "a~7E2Fb.svg"}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("WriteDiagrams does not write %s", file)
+		}
+	}
+	main := lexer.Diagrams()[0].SVG
+	index, _ := os.ReadFile(filepath.Join(dir, "index.html"))
+	for _, file := range files {
+		if !strings.Contains(main, `href="`+file+`"`) || !strings.Contains(string(index), `href="`+file+`"`) {
+			t.Errorf("The links to %s don't use its file name", file)
+		}
+	}
+}
+
+func TestWriteDiagrams(t *testing.T) {
+	dir := t.TempDir()
+	if err := Lex("a").Alias("a").WriteDiagrams(dir); err != nil {
+		t.Fatalf("WriteDiagrams fails: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.svg")); err != nil {
+		t.Error("WriteDiagrams does not write the diagram")
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil || !strings.Contains(string(index), `<a href="a.svg">a</a>`) {
+		t.Error("WriteDiagrams does not write an index linking the diagrams")
+	}
+}
@@ -0,0 +1,134 @@
+package abstract
+
+import (
+	"fmt"
+	"regexp/syntax"
+)
+
+//// Regular Expressions.
+
+// FromRegexp translates a Go regular expression into a Lexer. Greedy
+// repetitions munch and lazy ones try every count like Many, named groups
+// become aliases, and case-insensitive literals use LexFold. Anchors,
+// word boundaries and patterns that only match the empty string are
+// errors, since lexers have nowhere to put them.
+func FromRegexp(pattern string) (*Lexer, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	lexer, err := fromRegexp(re)
+	if err != nil {
+		return nil, fmt.Errorf("regexp %q: %s", pattern, err)
+	}
+	if lexer == nil {
+		return nil, fmt.Errorf("regexp %q: only matches the empty string", pattern)
+	}
+	return lexer, nil
+}
+
+// Translates re, giving nil when it only matches the empty string.
+func fromRegexp(re *syntax.Regexp) (*Lexer, error) {
+	subs := []*Lexer{}
+	for _, sub := range re.Sub {
+		lexer, err := fromRegexp(sub)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, lexer)
+	}
+	greedy := re.Flags&syntax.NonGreedy == 0
+
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil, fmt.Errorf("never matches")
+	case syntax.OpEmptyMatch:
+		return nil, nil
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return LexFold(string(re.Rune)), nil
+		}
+		return Lex(string(re.Rune)), nil
+	case syntax.OpCharClass:
+		ranges := []runeRange{}
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			ranges = append(ranges, runeRange{re.Rune[i], re.Rune[i+1]})
+		}
+		if len(ranges) == 0 {
+			return nil, fmt.Errorf("never matches")
+		}
+		return classLexer(newClass(ranges...)), nil
+	case syntax.OpAnyChar:
+		return Any(), nil
+	case syntax.OpAnyCharNotNL:
+		return NoneOf("\n"), nil
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		return nil, fmt.Errorf("anchors are not supported")
+	case syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return nil, fmt.Errorf("word boundaries are not supported")
+	case syntax.OpCapture:
+		if subs[0] != nil && re.Name != "" {
+			return Alias(subs[0], re.Name), nil
+		}
+		return subs[0], nil
+	case syntax.OpConcat:
+		parts := []*Lexer{}
+		for _, sub := range subs {
+			if sub != nil {
+				parts = append(parts, sub)
+			}
+		}
+		switch len(parts) {
+		case 0:
+			return nil, nil
+		case 1:
+			return parts[0], nil
+		}
+		return And(parts...), nil
+	case syntax.OpAlternate:
+		parts := []*Lexer{}
+		for _, sub := range subs {
+			if sub != nil {
+				parts = append(parts, sub)
+			}
+		}
+		if len(parts) == 0 {
+			return nil, nil
+		}
+		alternatives := parts[0]
+		if len(parts) > 1 {
+			alternatives = OneOf(parts...)
+		}
+		if len(parts) < len(subs) {
+			return Maybe(alternatives), nil
+		}
+		return alternatives, nil
+	}
+
+	sub := subs[0]
+	if sub == nil {
+		return nil, nil
+	}
+	switch re.Op {
+	case syntax.OpStar:
+		return regexpRepeat(sub, 0, UNBOUNDED, greedy), nil
+	case syntax.OpPlus:
+		return regexpRepeat(sub, 1, UNBOUNDED, greedy), nil
+	case syntax.OpQuest:
+		return Maybe(sub), nil
+	case syntax.OpRepeat:
+		if re.Max == 0 {
+			return nil, nil
+		}
+		return regexpRepeat(sub, re.Min, re.Max, greedy), nil
+	}
+	return nil, fmt.Errorf("%s is not supported", re)
+}
+
+// Repeat keeps bodies that can match nothing, like (a?)*, from looping.
+func regexpRepeat(lexer *Lexer, min int, max int, greedy bool) *Lexer {
+	if greedy {
+		return Repeat(lexer, min, max, POSSESSIVE)
+	}
+	return Repeat(lexer, min, max, LAZY)
+}
@@ -0,0 +1,65 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromRegexp(t *testing.T) {
+	number, err := FromRegexp(`(?P<int>[0-9]+)(\.[0-9]+)?(?i:e[+-]?\d{1,3})?`)
+	if err != nil {
+		t.Fatalf("FromRegexp fails: %s", err)
+	}
+	for _, str := range []string{"12", "1.5", "3E+10", "2e7"} {
+		if _, err := number.compileAll(str); err != nil {
+			t.Errorf("The translated regexp does not match %q", str)
+		}
+	}
+	if _, err := number.compileAll("1e1234"); err == nil {
+		t.Error("The translated regexp repeats too often")
+	}
+	if number.MustCompile("12").Tokens()[0].Name != "int" {
+		t.Error("Named groups don't become aliases")
+	}
+
+	// Greedy repetitions munch, lazy ones try every count.
+	if len(mustRegexp(t, `a+`).Compile("aaa")) != 1 || len(mustRegexp(t, `a+?`).Compile("aaa")) != 3 {
+		t.Error("Greedy and lazy repetitions are mixed up")
+	}
+	// Bodies that can match nothing don't loop.
+	for _, pattern := range []string{`(a?)*b`, `(a|)+b`, `(a?)*?b`} {
+		if _, err := mustRegexp(t, pattern).compileAll("aab"); err != nil {
+			t.Errorf("%s does not match aab", pattern)
+		}
+	}
+	if !mustRegexp(t, `x|`).Match("") || !mustRegexp(t, `.`).Match("é") || mustRegexp(t, `.`).Match("\n") {
+		t.Error("Empty alternatives or . are wrong")
+	}
+}
+
+func mustRegexp(t *testing.T, pattern string) *Lexer {
+	lexer, err := FromRegexp(pattern)
+	if err != nil {
+		t.Fatalf("FromRegexp(%q) fails: %s", pattern, err)
+	}
+	return lexer
+}
+
+func TestFromRegexpErrors(t *testing.T) {
+	errors := map[string]string{
+		`^abc`:               "anchors are not supported",
+		`a\b`:                "word boundaries are not supported",
+		`()`:                 "only matches the empty string",
+		`[^\x00-\x{10FFFF}]`: "never matches",
+		`(a)\1`:              "invalid escape sequence",
+		`a(`:                 "missing closing )",
+	}
+	for pattern, expected := range errors {
+		_, err := FromRegexp(pattern)
+		if err == nil {
+			t.Errorf("FromRegexp accepts %q", pattern)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Errorf("FromRegexp(%q) gives %q", pattern, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package abstract
+
+//// Repetition.
+
+type RepeatMode int
+
+const (
+	GREEDY     RepeatMode = iota // Every count, the most first.
+	LAZY                         // Every count, the fewest first, like Many.
+	POSSESSIVE                   // Only the most, like Munch.
+)
+
+// The max of a Repeat without an upper limit.
+const UNBOUNDED = -1
+
+// Repeat matches lexer between min and max times, both included, or at
+// least min times if max is UNBOUNDED. The mode decides which counts it
+// gives results for, and in which order.
+//
+//	Repeat(x, 0, UNBOUNDED, POSSESSIVE) // Maybe(Munch(x))
+//	Repeat(x, 1, UNBOUNDED, LAZY)       // Many(x)
+//	Repeat(x, 2, 4, GREEDY)             // 4, 3 or 2 x's
+func Repeat(lexer *Lexer, min int, max int, mode RepeatMode) *Lexer {
+	if min < 0 || (max != UNBOUNDED && max < min) {
+		panic("Repeat requires 0 <= min <= max, or max == UNBOUNDED.")
+	}
+	if mode != GREEDY && mode != LAZY && mode != POSSESSIVE {
+		panic("Repeat requires GREEDY, LAZY or POSSESSIVE.")
+	}
+	b := base()
+	b.action = REPEAT
+	b.from = min
+	b.to = max
+	b.mode = mode
+	b.children = append(b.children, lexer)
+	return b
+}
+
+// NMunch munches lexer between min and max times, both included.
+func NMunch(lexer *Lexer, min int, max int) *Lexer {
+	return Repeat(lexer, min, max, POSSESSIVE)
+}
+
+// NMany written with Peek and Repeat, for EBNF and Diagrams. NMany gives
+// every count from 1 up to its max, the fewest first, and NMany(x, n, max)
+// only does so where x matches at least n times in a row.
+func (self *Lexer) nmanyExpansion() *Lexer {
+	child := self.children[0]
+	least := self.from - 1
+	if self.to < 1 || least > self.to {
+		return Not(Maybe(child)) // Never matches.
+	}
+	counts := Repeat(child, 1, self.to, LAZY)
+	if least <= 1 {
+		return counts
+	}
+	return And(Peek(Repeat(child, least, least, GREEDY)), counts)
+}
+
+// Whether a round of repetition, from result to next, took any text.
+// Repeat and SepBy stop at rounds that don't: a lexer that matches
+// nothing can do so forever, and the repetition would never end.
+func progressed(result *Result, next *Result) bool {
+	return next.left_over != result.left_over
+}
+
+func (self *Lexer) compileRepeat(str string, captures *binding) []*Result {
+	// levels[i] holds the results of matching i times.
+	levels := [][]*Result{singleResult([]*Token{}, str, captures)}
+	for count := 1; self.to == UNBOUNDED || count <= self.to; count++ {
+		next := []*Result{}
+		for _, result := range levels[count-1] {
+			for _, res := range self.children[0].compile(result.left_over, result.captures) {
+				// The rounds up to min may take nothing.
+				if !progressed(result, res) && count > self.from {
+					continue
+				}
+				next = append(next, extend(result, res))
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		levels = append(levels, next)
+	}
+
+	most := len(levels) - 1
+	if most < self.from {
+		return []*Result{}
+	}
+	out := []*Result{}
+	switch self.mode {
+	case POSSESSIVE:
+		out = levels[most]
+	case GREEDY:
+		for i := most; i >= self.from; i-- {
+			out = append(out, levels[i]...)
+		}
+	case LAZY:
+		for i := self.from; i <= most; i++ {
+			out = append(out, levels[i]...)
+		}
+	}
+	return out
+}
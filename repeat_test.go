@@ -0,0 +1,79 @@
+package abstract
+
+import (
+	"testing"
+)
+
+// How much of str each result consumes, in order.
+func consumed(results []*Result, str string) []int {
+	out := []int{}
+	for _, result := range results {
+		out = append(out, len(str)-len(result.left_over))
+	}
+	return out
+}
+
+func TestRepeat(t *testing.T) {
+	modes := map[RepeatMode][]int{
+		GREEDY:     {4, 3, 2},
+		LAZY:       {2, 3, 4},
+		POSSESSIVE: {4},
+	}
+	for mode, expected := range modes {
+		results := consumed(Repeat(a, 2, 4, mode).Compile("aaaaa"), "aaaaa")
+		if len(results) != len(expected) {
+			t.Errorf("Repeat in mode %d consumes %v instead of %v", mode, results, expected)
+			continue
+		}
+		for i := range results {
+			if results[i] != expected[i] {
+				t.Errorf("Repeat in mode %d consumes %v instead of %v", mode, results, expected)
+				break
+			}
+		}
+	}
+
+	if len(Repeat(a, 2, 4, GREEDY).Compile("ab")) != 0 {
+		t.Error("Repeat matches fewer than min times")
+	}
+	if !Repeat(a, 0, UNBOUNDED, POSSESSIVE).Match("b") {
+		t.Error("Repeat does not match zero times")
+	}
+	if len(Repeat(a, 1, UNBOUNDED, LAZY).Compile("aaa")) != 3 {
+		t.Error("Repeat does not match without an upper limit")
+	}
+	if len(Repeat(Maybe(a), 0, UNBOUNDED, GREEDY).Compile("b")) == 0 {
+		t.Error("Repeat of a lexer matching nothing fails")
+	}
+	if consumed(NMunch(a, 1, 3).Compile("aaaaa"), "aaaaa")[0] != 3 {
+		t.Error("NMunch does not munch up to max")
+	}
+}
+
+func TestRepeatBounds(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Repeat takes max < min")
+		}
+	}()
+	Repeat(a, 3, 2, GREEDY)
+}
+
+func TestRepeatRules(t *testing.T) {
+	rules, err := LoadRules(`greedy = "a"{2,4} ; lazy = "a"{2,}? ; possessive = "a"{2,4}+ ;`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if consumed(rules["greedy"].Compile("aaa"), "aaa")[0] != 3 || consumed(rules["lazy"].Compile("aaa"), "aaa")[0] != 2 {
+		t.Error("{m,n} and {m,n}? load in the wrong order")
+	}
+	if len(rules["possessive"].Compile("aaa")) != 1 {
+		t.Error("{m,n}+ does not munch")
+	}
+	for name, lexer := range rules {
+		ebnf := lexer.EBNF()
+		if reloaded, err := LoadRules(ebnf); err != nil || len(reloaded["main"].Compile("aaaaa")) != len(lexer.Compile("aaaaa")) {
+			t.Errorf("EBNF writes %s for %s", ebnf, name)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package abstract
+
+import (
+	"strings"
+)
+
+//// Token Rewriting.
+
+// A Rewriter changes the tokens of a Result before the tree is built from
+// them, like Offside does. Grammar.Rewrite runs rewriters as part of Parse.
+type Rewriter interface {
+	Rewrite(tokens []*Token) ([]*Token, error)
+}
+
+// An Insertion is a rule for adding a virtual token at the end of a line,
+// the way Go adds semicolons:
+//
+//	Insertion{Name: ";", After: []string{"ident", "int", "string", ")", "]", "}", "return"}, AtEnd: true}
+type Insertion struct {
+	Name   string   // The token to add, which stands for no text.
+	After  []string // It goes after a line's last token if it has one of these names,
+	Unless []string // and the next line doesn't start with one of these names.
+	AtEnd  bool     // It also goes after the last token when the input doesn't end the line.
+}
+
+// Insertions adds the virtual tokens of its rules. Only the first rule
+// that applies at a line end adds its token.
+type Insertions struct {
+	Rules []Insertion
+	Skip  []string // Tokens that don't end a line, like comments.
+}
+
+func hasName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewrite adds the tokens right after the last token of each line, before
+// any whitespace or skipped tokens that follow it, so the tree's Source is
+// unchanged. A line ends where a token holds a newline, or a token that
+// isn't skipped ends with one.
+func (self Insertions) Rewrite(tokens []*Token) ([]*Token, error) {
+	out := []*Token{}
+	last := -1 // Where in out the last token that counts is.
+	newline := false
+
+	insert := func(next *Token) {
+		if last < 0 || next != nil && !newline {
+			return
+		}
+		for _, rule := range self.Rules {
+			if !hasName(rule.After, out[last].Name) {
+				continue
+			}
+			if next == nil && !newline && !rule.AtEnd || next != nil && hasName(rule.Unless, next.Name) {
+				continue
+			}
+			out = append(out[:last+1], append([]*Token{virtualToken(rule.Name, "")}, out[last+1:]...)...)
+			return
+		}
+	}
+
+	for _, tok := range tokens {
+		source := tok.source()
+		if isLayout(source) || hasName(self.Skip, tok.Name) {
+			newline = newline || strings.ContainsRune(source, '\n')
+			out = append(out, tok)
+			continue
+		}
+		insert(tok)
+		out = append(out, tok)
+		last, newline = len(out)-1, strings.HasSuffix(source, "\n")
+	}
+	insert(nil)
+	return out, nil
+}
+
+// The names of the tokens a rewriter adds, for Grammar.Validate.
+func (self Insertions) produces() []string {
+	names := []string{}
+	for _, rule := range self.Rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+func (self Offside) produces() []string {
+	return []string{"indent", "dedent", "newline"}
+}
@@ -0,0 +1,76 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+var semicolons = Insertions{
+	Rules: []Insertion{{Name: ";", After: []string{"name", "number", ")"}, Unless: []string{"."}, AtEnd: true}},
+	Skip:  []string{"comment"},
+}
+
+func statementTokens(str string) []*Token {
+	lexer := Maybe(Munch(OneOf(
+		Munch(Alpha).Alias("name"),
+		Munch(Digit).Alias("number"),
+		OneOfString("=", "(", ")", ".", ";"),
+		And(Lex("#"), Until(Lex("\n"))).Alias("comment"),
+		Munch(Space).Garbage(),
+	)))
+	return lexer.MustCompile(str).Tokens()
+}
+
+func TestInsertions(t *testing.T) {
+	input := "x = 1 # one\ny = f(x)\n\n  .g()\nz = 2"
+	tokens, err := semicolons.Rewrite(statementTokens(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := tokenNames(tokens); names != "name = number ; comment name = name ( name ) . name ( ) ; name = number ;" {
+		t.Errorf("The tokens are %s", names)
+	}
+	if AbstractParent(tokens).Source() != input {
+		t.Error("The inserted tokens change the source")
+	}
+
+	// A ; that is already there is not a name, so nothing more is added.
+	tokens, _ = semicolons.Rewrite(statementTokens("x = 1;\ny = 2;"))
+	if names := tokenNames(tokens); names != "name = number ; name = number ;" {
+		t.Errorf("The tokens are %s", names)
+	}
+
+	// Without AtEnd, only the end of a line counts.
+	rules := Insertions{Rules: []Insertion{{Name: ";", After: []string{"number"}}}}
+	tokens, _ = rules.Rewrite(statementTokens("x = 1\ny = 2"))
+	if names := tokenNames(tokens); names != "name = number ; name = number" {
+		t.Errorf("The tokens are %s", names)
+	}
+}
+
+func TestGrammarRewrite(t *testing.T) {
+	lexer := Maybe(Munch(OneOf(Munch(Alpha).Alias("name"), Lex("="), Lex(";"), Munch(Space).Alias("space"))))
+	grammar := NewGrammar(lexer).
+		Rewrite(Insertions{Rules: []Insertion{{Name: ";", After: []string{"name"}, AtEnd: true}}}).
+		Skip("space").
+		Operator("=", 1, 1).
+		Operator(";", 1, 0)
+	if err := grammar.Validate(); err != nil {
+		t.Fatalf("Validate does not know the inserted tokens: %s", err)
+	}
+	tree, err := grammar.Parse("a = b\nc = d")
+	if err != nil {
+		t.Fatalf("Grammar does not parse: %s", err)
+	}
+	if len(tree.Children) != 2 || tree.Children[0].Token.Name != ";" || tree.Children[1].Token.Name != ";" {
+		t.Errorf("The statements are %s", tree)
+	}
+
+	offside := NewGrammar(lexer).Rewrite(Offside{}).Between("indent", "dedent")
+	if err := offside.Validate(); err != nil {
+		t.Errorf("Validate does not know the tokens of Offside: %s", err)
+	}
+	if _, err := NewGrammar(lexer).Rewrite(Offside{}).Parse("a\n  b\n c"); err == nil || !strings.Contains(err.Error(), "3:2") {
+		t.Errorf("Parse gives %v for bad indentation", err)
+	}
+}
@@ -0,0 +1,206 @@
+package abstract
+
+import (
+	"fmt"
+)
+
+//// Rule Sets.
+//
+// The text grammar formats are first parsed into ruleExprs, which are
+// then built into Lexers once every rule is known, since a rule may
+// refer to rules defined after it.
+
+type ruleKind int
+
+const (
+	ruleRef ruleKind = iota
+	ruleLiteral
+	ruleClass
+	ruleAnd
+	ruleOneOf
+	ruleFirstOf
+	ruleRepeat
+	ruleMaybe
+	ruleAlias
+	ruleGarbage
+	rulePeek
+	ruleNot
+	ruleCapture
+	ruleBackref
+)
+
+type ruleExpr struct {
+	kind     ruleKind
+	text     string     // The literal, the rule referred to, the alias or the capture.
+	ranges   [][2]rune  // Only used for ruleClass.
+	negated  bool       // Only used for ruleClass.
+	min      int        // Only used for ruleRepeat.
+	max      int        // Only used for ruleRepeat, may be UNBOUNDED.
+	mode     RepeatMode // Only used for ruleRepeat.
+	fold     bool       // Only used for ruleLiteral: match either case.
+	children []*ruleExpr
+	pos      int // Where the expression starts in the grammar text.
+}
+
+type GrammarError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (self *GrammarError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", self.Line, self.Column, self.Message)
+}
+
+// Turns an offset into the grammar text into a GrammarError.
+func grammarError(text []rune, pos int, format string, args ...interface{}) *GrammarError {
+	line, column := 1, 1
+	for _, r := range text[:pos] {
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &GrammarError{line, column, fmt.Sprintf(format, args...)}
+}
+
+type ruleSet struct {
+	text     []rune
+	order    []string // Rule names in the order they were defined.
+	defs     map[string]*ruleExpr
+	builtins map[string]*Lexer
+	key      func(string) string // How rule names are compared.
+
+	built    map[string]*Lexer
+	building map[string]bool
+}
+
+func newRuleSet(text []rune, builtins map[string]*Lexer, key func(string) string) *ruleSet {
+	return &ruleSet{
+		text:     text,
+		defs:     map[string]*ruleExpr{},
+		builtins: builtins,
+		key:      key,
+		built:    map[string]*Lexer{},
+		building: map[string]bool{},
+	}
+}
+
+func (self *ruleSet) define(name string, expr *ruleExpr) {
+	key := self.key(name)
+	if _, ok := self.defs[key]; !ok {
+		self.order = append(self.order, name)
+	}
+	self.defs[key] = expr
+}
+
+// Builds every rule, keyed by the name it was first defined with.
+func (self *ruleSet) build() (map[string]*Lexer, error) {
+	out := map[string]*Lexer{}
+	for _, name := range self.order {
+		lexer, err := self.rule(name, self.defs[self.key(name)])
+		if err != nil {
+			return nil, err
+		}
+		out[name] = lexer
+	}
+	return out, nil
+}
+
+func (self *ruleSet) rule(name string, at *ruleExpr) (*Lexer, error) {
+	key := self.key(name)
+	if lexer, ok := self.built[key]; ok {
+		return lexer, nil
+	}
+	expr, ok := self.defs[key]
+	if !ok {
+		if lexer, ok := self.builtins[key]; ok {
+			return lexer, nil
+		}
+		return nil, grammarError(self.text, at.pos, "undefined rule %q", name)
+	}
+	// Lexers can't refer to themselves, so neither can rules.
+	if self.building[key] {
+		return nil, grammarError(self.text, at.pos, "rule %q refers to itself", name)
+	}
+	self.building[key] = true
+	lexer, err := self.expr(expr)
+	delete(self.building, key)
+	if err != nil {
+		return nil, err
+	}
+	self.built[key] = lexer
+	return lexer, nil
+}
+
+func (self *ruleSet) expr(expr *ruleExpr) (*Lexer, error) {
+	children := make([]*Lexer, len(expr.children))
+	for i, child := range expr.children {
+		lexer, err := self.expr(child)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = lexer
+	}
+
+	switch expr.kind {
+	case ruleRef:
+		return self.rule(expr.text, expr)
+	case ruleLiteral:
+		if expr.fold {
+			return LexFold(expr.text), nil
+		}
+		return Lex(expr.text), nil
+	case ruleClass:
+		if expr.negated {
+			return Except(Any(), charRanges(expr.ranges...)), nil
+		}
+		return charRanges(expr.ranges...), nil
+	case ruleAnd:
+		if len(children) == 1 {
+			return children[0], nil
+		}
+		return And(children...), nil
+	case ruleOneOf:
+		return OneOf(children...), nil
+	case ruleFirstOf:
+		return FirstOf(children...), nil
+	case ruleMaybe:
+		return Maybe(children[0]), nil
+	case ruleAlias:
+		return Alias(children[0], expr.text), nil
+	case ruleGarbage:
+		return Garbage(children[0]), nil
+	case rulePeek:
+		return Peek(children[0]), nil
+	case ruleNot:
+		return Not(children[0]), nil
+	case ruleCapture:
+		return Capture(expr.text, children[0]), nil
+	case ruleBackref:
+		return Backref(expr.text), nil
+	case ruleRepeat:
+		return self.repeat(expr, children[0])
+	}
+	panic("Unknown rule expression.")
+}
+
+// Matches any one character within the ranges.
+func charRanges(ranges ...[2]rune) *Lexer {
+	out := []runeRange{}
+	for _, r := range ranges {
+		out = append(out, runeRange{r[0], r[1]})
+	}
+	return classLexer(newClass(out...))
+}
+
+// Repeat skips matches that make no progress, so ("a"?)* doesn't loop.
+func (self *ruleSet) repeat(expr *ruleExpr, lexer *Lexer) (*Lexer, error) {
+	min, max := expr.min, expr.max
+	if max != UNBOUNDED && (max < min || max == 0) {
+		return nil, grammarError(self.text, expr.pos, "invalid repetition {%d,%d}", min, max)
+	}
+	return Repeat(lexer, min, max, expr.mode), nil
+}
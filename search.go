@@ -0,0 +1,148 @@
+package abstract
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+//// Searching.
+//
+// Compile only matches at the start of a string. These scan the string
+// for the leftmost match instead, like the regexp package. Among the
+// results at that position, they take the first one the lexer gives,
+// or the one that consumes the most text once Longest is called.
+
+// Longest returns a copy of the lexer that searches for the longest match
+// at each position instead of the first result.
+func (self *Lexer) Longest() *Lexer {
+	lexer := *self
+	lexer.longest = true
+	return &lexer
+}
+
+// The chosen result of matching at the start of str, and how much it consumes.
+func (self *Lexer) matchAt(str string) (*Result, int, bool) {
+	results := self.Compile(str)
+	if len(results) == 0 {
+		return nil, 0, false
+	}
+	best := results[0]
+	if self.longest {
+		for _, result := range results[1:] {
+			if len(result.left_over) < len(best.left_over) {
+				best = result
+			}
+		}
+	}
+	return best, len(str) - len(best.left_over), true
+}
+
+// Finds the leftmost match starting at or after pos.
+func (self *Lexer) find(str string, pos int) (*Result, int, int, bool) {
+	for i := pos; i <= len(str); {
+		if result, size, ok := self.matchAt(str[i:]); ok {
+			return result, i, i + size, true
+		}
+		if i == len(str) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(str[i:])
+		i += size
+	}
+	return nil, 0, 0, false
+}
+
+// Calls deliver with the successive non-overlapping matches, at most n
+// of them if n >= 0. Like regexp, an empty match right after another
+// match is skipped.
+func (self *Lexer) allMatches(str string, n int, deliver func(result *Result, start int, end int)) {
+	pos, prev_end, count := 0, -1, 0
+	for pos <= len(str) && (n < 0 || count < n) {
+		result, start, end, ok := self.find(str, pos)
+		if !ok {
+			break
+		}
+		if end > start || start != prev_end {
+			deliver(result, start, end)
+			count++
+		}
+		prev_end = end
+		if end > start {
+			pos = end
+		} else if start < len(str) {
+			_, size := utf8.DecodeRuneInString(str[start:])
+			pos = start + size
+		} else {
+			break
+		}
+	}
+}
+
+// FindIndex gives the start and end of the leftmost match in str, or nil.
+func (self *Lexer) FindIndex(str string) []int {
+	if _, start, end, ok := self.find(str, 0); ok {
+		return []int{start, end}
+	}
+	return nil
+}
+
+// FindAllIndex gives the start and end of each successive match in str,
+// at most n of them if n >= 0, or nil if there are none.
+func (self *Lexer) FindAllIndex(str string, n int) [][]int {
+	var out [][]int
+	self.allMatches(str, n, func(result *Result, start int, end int) {
+		out = append(out, []int{start, end})
+	})
+	return out
+}
+
+// FindAllTokens gives the tokens of each successive match in str,
+// at most n of them if n >= 0, or nil if there are none.
+func (self *Lexer) FindAllTokens(str string, n int) [][]*Token {
+	var out [][]*Token
+	self.allMatches(str, n, func(result *Result, start int, end int) {
+		out = append(out, result.Tokens())
+	})
+	return out
+}
+
+// ReplaceAllFunc replaces each match in src with what repl returns for it.
+func (self *Lexer) ReplaceAllFunc(src string, repl func(*Result) string) string {
+	var out strings.Builder
+	last := 0
+	self.allMatches(src, -1, func(result *Result, start int, end int) {
+		out.WriteString(src[last:start])
+		out.WriteString(repl(result))
+		last = end
+	})
+	out.WriteString(src[last:])
+	return out.String()
+}
+
+// Split slices str into the substrings between the matches, like
+// regexp's Split. If n >= 0, it gives at most n substrings, the last
+// being the rest of str.
+func (self *Lexer) Split(str string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	if str == "" {
+		return []string{""}
+	}
+	out := []string{}
+	begin, end := 0, 0
+	for _, match := range self.FindAllIndex(str, n) {
+		if n > 0 && len(out) == n-1 {
+			break
+		}
+		end = match[0]
+		if match[1] != 0 {
+			out = append(out, str[begin:end])
+		}
+		begin = match[1]
+	}
+	if end != len(str) {
+		out = append(out, str[begin:])
+	}
+	return out
+}
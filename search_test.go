@@ -0,0 +1,73 @@
+package abstract
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	number := Munch(Digit).Alias("number")
+	if !reflect.DeepEqual(number.FindIndex("abc 123 45"), []int{4, 7}) {
+		t.Errorf("FindIndex gives %v", number.FindIndex("abc 123 45"))
+	}
+	if number.FindIndex("abc") != nil {
+		t.Error("FindIndex finds a number in \"abc\"")
+	}
+	all := number.FindAllIndex("é1 23 456", -1)
+	if !reflect.DeepEqual(all, [][]int{{2, 3}, {4, 6}, {7, 10}}) {
+		t.Errorf("FindAllIndex gives %v", all)
+	}
+	if len(number.FindAllIndex("1 2 3", 2)) != 2 {
+		t.Error("FindAllIndex does not stop after n matches")
+	}
+
+	tokens := number.FindAllTokens("x=1, y=22", -1)
+	if len(tokens) != 2 || tokens[1][0].Value != "22" {
+		t.Errorf("FindAllTokens gives %v", tokens)
+	}
+}
+
+func TestFindLongest(t *testing.T) {
+	// Many gives its shortest result first.
+	lexer := Many(Lex("a"))
+	if !reflect.DeepEqual(lexer.FindIndex("baaa"), []int{1, 2}) {
+		t.Errorf("FindIndex gives %v", lexer.FindIndex("baaa"))
+	}
+	if !reflect.DeepEqual(lexer.Longest().FindIndex("baaa"), []int{1, 4}) {
+		t.Errorf("FindIndex of Longest gives %v", lexer.Longest().FindIndex("baaa"))
+	}
+	if lexer.longest {
+		t.Error("Longest changes the lexer it is called on")
+	}
+}
+
+func TestReplaceAndSplit(t *testing.T) {
+	number := Munch(Digit).Alias("number")
+	doubled := number.ReplaceAllFunc("1 + 22", func(result *Result) string {
+		return strings.Repeat(result.Tokens()[0].Value, 2)
+	})
+	if doubled != "11 + 2222" {
+		t.Errorf("ReplaceAllFunc gives %q", doubled)
+	}
+
+	comma := And(Lex(","), Maybe(Munch(Lex(" "))))
+	if !reflect.DeepEqual(comma.Split("a, b,c", -1), []string{"a", "b", "c"}) {
+		t.Errorf("Split gives %q", comma.Split("a, b,c", -1))
+	}
+	if !reflect.DeepEqual(comma.Split("a, b,c", 2), []string{"a", "b,c"}) {
+		t.Errorf("Split gives %q", comma.Split("a, b,c", 2))
+	}
+	if !reflect.DeepEqual(comma.Split("", -1), []string{""}) {
+		t.Errorf("Split gives %q for no input", comma.Split("", -1))
+	}
+
+	// Empty matches split between characters, like regexp.
+	empty := Maybe(Lex("x"))
+	if !reflect.DeepEqual(empty.Split("abé", -1), []string{"a", "b", "é"}) {
+		t.Errorf("Split on empty matches gives %q", empty.Split("abé", -1))
+	}
+	if empty.ReplaceAllFunc("ab", func(*Result) string { return "-" }) != "-a-b-" {
+		t.Error("ReplaceAllFunc does not replace empty matches")
+	}
+}
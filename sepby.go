@@ -0,0 +1,96 @@
+package abstract
+
+//// Separated Lists.
+
+// SepBy1 matches one or more items separated by sep, like
+// And(item, Maybe(Munch(And(sep, item)))): it takes as many items as it
+// can instead of giving a result for every length. To leave the
+// separators out of the tokens, pass sep.Garbage().
+func SepBy1(item *Lexer, sep *Lexer) *Lexer {
+	b := base()
+	b.action = SEPBY
+	b.from = 1
+	b.children = append(b.children, item, sep)
+	return b
+}
+
+// SepBy is SepBy1, but also matches an empty list.
+func SepBy(item *Lexer, sep *Lexer) *Lexer {
+	b := SepBy1(item, sep)
+	b.from = 0
+	return b
+}
+
+// Trailing returns a copy of a SepBy or SepBy1 lexer that also takes a
+// separator after the last item, as in "[1, 2, 3,]".
+func (self *Lexer) Trailing() *Lexer {
+	if self.action != SEPBY {
+		panic("Trailing only applies to SepBy and SepBy1.")
+	}
+	lexer := *self
+	lexer.trailing = true
+	return &lexer
+}
+
+// The tokens of result followed by those of next, which starts where result stops.
+func extend(result *Result, next *Result) *Result {
+	tokens := append(append([]*Token{}, result.tokens...), next.tokens...)
+	return &Result{tokens: tokens, left_over: next.left_over, captures: next.captures}
+}
+
+func (self *Lexer) compileSepBy(str string, captures *binding) []*Result {
+	item, sep := self.children[0], self.children[1]
+	current := item.compile(str, captures)
+	if len(current) == 0 {
+		if self.from == 0 {
+			return singleResult([]*Token{}, str, captures)
+		}
+		return []*Result{}
+	}
+
+	for {
+		next := []*Result{}
+		for _, result := range current {
+			for _, separated := range sep.compile(result.left_over, result.captures) {
+				for _, res := range item.compile(separated.left_over, separated.captures) {
+					if progressed(result, res) {
+						next = append(next, extend(extend(result, separated), res))
+					}
+				}
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		current = next
+	}
+
+	if !self.trailing {
+		return current
+	}
+	out := []*Result{}
+	for _, result := range current {
+		separated := sep.compile(result.left_over, result.captures)
+		if len(separated) == 0 {
+			out = append(out, result)
+		}
+		for _, res := range separated {
+			out = append(out, extend(result, res))
+		}
+	}
+	return out
+}
+
+// The same list, written with And, Maybe and Munch.
+func (self *Lexer) sepByExpansion() *Lexer {
+	item, sep := self.children[0], self.children[1]
+	parts := []*Lexer{item, Maybe(Munch(And(sep, item)))}
+	if self.trailing {
+		parts = append(parts, Maybe(sep))
+	}
+	list := And(parts...)
+	if self.from == 0 {
+		return Maybe(list)
+	}
+	return list
+}
@@ -0,0 +1,41 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestSepBy(t *testing.T) {
+	number := Munch(Digit).Alias("number")
+	comma := And(Lex(","), Maybe(Munch(Lex(" "))))
+	list := SepBy1(number, comma.Garbage())
+
+	results := list.Compile("1, 22,333 x")
+	if len(results) != 1 || results[0].left_over != " x" {
+		t.Fatalf("SepBy1 does not munch the whole list: %d results", len(results))
+	}
+	tokens := results[0].Tokens()
+	if len(tokens) != 5 || tokens[2].Value != "22" || tokens[1].Value != "" {
+		t.Errorf("SepBy1 gives the tokens %v", tokens)
+	}
+	if list.Match("") || !SepBy(number, comma).Match("") {
+		t.Error("SepBy1 matches an empty list, or SepBy doesn't")
+	}
+
+	// Without Trailing, the last comma is left over.
+	if SepBy(number, comma).Compile("1,2,")[0].left_over != "," {
+		t.Error("SepBy takes a trailing separator")
+	}
+	if SepBy(number, comma).Trailing().Compile("1,2,")[0].left_over != "" {
+		t.Error("Trailing does not take a trailing separator")
+	}
+}
+
+func TestSepByEBNF(t *testing.T) {
+	lexer := SepBy(Lex("a"), Lex(",")).Trailing()
+	if lexer.EBNF() != "main = (\"a\" (\",\" \"a\")* \",\"?)? ;\n" {
+		t.Errorf("EBNF writes %s", lexer.EBNF())
+	}
+	if err := wellFormed(lexer.Diagrams()[0].SVG); err != nil {
+		t.Errorf("The diagram of a SepBy is not well-formed: %s", err)
+	}
+}
@@ -0,0 +1,98 @@
+package abstract
+
+//// Skipping Whitespace.
+
+// WithSkip returns a copy of the lexer that also takes as much text as
+// skip matches before each of its tokens and at the end, so spaces don't
+// have to be written between every part of every And:
+//
+//	And(Lex("let"), ident, Lex("="), number).WithSkip(Space)
+//
+// The skipped text becomes garbage tokens, which keep it for Source.
+// A token is a literal, a class, an alias, a StringLiteral, an Until, a
+// Capture, Keywords or an Atomic lexer: text is never skipped inside
+// them. Peek and Not look at the text as it is, without skipping.
+func (self *Lexer) WithSkip(skip *Lexer) *Lexer {
+	lexer := withSkip(self, skip, map[*Lexer]*Lexer{})
+	if lexer.action == AND && lexer.token == "" {
+		return And(append(append([]*Lexer{}, lexer.children...), skipBefore(skip, nil))...)
+	}
+	return And(lexer, skipBefore(skip, nil))
+}
+
+// Atomic matches like lexer, but WithSkip treats it as a single token and
+// doesn't skip anything inside it. Use it for comments and other text where
+// whitespace matters.
+func Atomic(lexer *Lexer) *Lexer {
+	b := base()
+	b.action = ATOMIC
+	b.children = append(b.children, lexer)
+	return b
+}
+
+// Skip before lexer, or only skip if lexer is nil.
+func skipBefore(skip *Lexer, lexer *Lexer) *Lexer {
+	b := base()
+	b.action = SKIP
+	b.children = append(b.children, skip)
+	if lexer != nil {
+		b.children = append(b.children, lexer)
+	}
+	return b
+}
+
+// Copies lexer with skipping before its tokens. Lexers that are used in
+// several places, or in themselves, are only copied once.
+func withSkip(lexer *Lexer, skip *Lexer, copies map[*Lexer]*Lexer) *Lexer {
+	if c, ok := copies[lexer]; ok {
+		return c
+	}
+	switch lexer.action {
+	case PEEK, NOT:
+		return lexer
+	case ATOMIC, UNTIL, CAPTURE, SKIP, KEYWORDS:
+		copies[lexer] = skipBefore(skip, lexer)
+		return copies[lexer]
+	}
+	if len(lexer.children) == 0 || lexer.token != "" {
+		copies[lexer] = skipBefore(skip, lexer)
+		return copies[lexer]
+	}
+
+	c := *lexer
+	c.children = make([]*Lexer, len(lexer.children))
+	copies[lexer] = &c
+	for i, child := range lexer.children {
+		c.children[i] = withSkip(child, skip, copies)
+	}
+	return &c
+}
+
+func (self *Lexer) compileSkip(str string, captures *binding) []*Result {
+	rest := str
+	for {
+		next := rest
+		for _, result := range self.children[0].compile(rest, captures) {
+			if len(result.left_over) < len(next) {
+				next = result.left_over
+			}
+		}
+		if next == rest {
+			break
+		}
+		rest = next
+	}
+
+	skipped := []*Token{}
+	if rest != str {
+		skipped = append(skipped, &Token{Name: garbage, raw: str[:len(str)-len(rest)], has_raw: true})
+	}
+	if len(self.children) == 1 {
+		return singleResult(skipped, rest, captures)
+	}
+	results := self.children[1].compile(rest, captures)
+	for _, result := range results {
+		result.tokens = append(append([]*Token{}, skipped...), result.tokens...)
+	}
+	return results
+}
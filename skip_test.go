@@ -0,0 +1,81 @@
+package abstract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithSkip(t *testing.T) {
+	ident := Munch(Lower).Alias("ident")
+	number := Munch(Digit).Alias("number")
+	let := And(Lex("let"), Not(Alphanumeric), ident, Lex("="), number, Lex(";")).WithSkip(Space)
+
+	input := "  let x =\n 12 ; "
+	result := let.MustCompile(input)
+	tree := AbstractFromResult(result)
+	if tree.Source() != input {
+		t.Errorf("The source is %q", tree.Source())
+	}
+	tree.Filter(garbage)
+	if names := tokenNames(result.Tokens()); names != "let ident = number ;" {
+		t.Errorf("The tokens are %s", names)
+	}
+	if len(let.Compile(" let x=12;")) != 1 {
+		t.Error("WithSkip gives more than one result")
+	}
+
+	// Aliases are tokens, and Not looks right after "let".
+	if let.Match("let x = 1 2;") {
+		t.Error("WithSkip skips inside an alias")
+	}
+	if let.Match("letx = 1;") {
+		t.Error("WithSkip skips before a Not")
+	}
+}
+
+func TestAtomic(t *testing.T) {
+	comment := Atomic(And(Lex("#"), Until(OneOf(Lex("\n"), Eof)))).Alias("comment")
+	hex := Atomic(And(Lex("0"), Lex("x"), Munch(Digit)))
+	lexer := Maybe(Munch(OneOf(hex, comment, Munch(Alpha).Alias("name")))).WithSkip(Space)
+
+	result, err := lexer.compileAll("a 0x12 # a  comment\nb")
+	if err != nil {
+		t.Fatalf("WithSkip fails: %s", err)
+	}
+	tokens := []string{}
+	for _, tok := range result.Tokens() {
+		if tok.Name != garbage {
+			tokens = append(tokens, tok.String())
+		}
+	}
+	if strings.Join(tokens, " ") != "name:a 0:0 x:x 1:1 2:2 comment:# a  comment name:b" {
+		t.Errorf("The tokens are %s", strings.Join(tokens, " "))
+	}
+	if _, err := lexer.compileAll("0 x12"); err == nil {
+		t.Error("WithSkip skips inside Atomic")
+	}
+}
+
+func TestWithSkipShared(t *testing.T) {
+	// The parentheses refer to themselves.
+	parens := And(Lex("("))
+	parens.children = append(parens.children, Maybe(Munch(parens)), Lex(")"))
+	parens = parens.WithSkip(Space)
+	if _, err := parens.compileAll("( ( ) ( ) )"); err != nil {
+		t.Errorf("WithSkip does not handle recursion: %s", err)
+	}
+
+	lexer := And(a, b).WithSkip(Space)
+	if a.action != NONE || len(And(a, b).MustCompile("ab").Tokens()) != 2 {
+		t.Error("WithSkip changes the lexer it copies")
+	}
+	if ebnf := lexer.EBNF(); !strings.Contains(ebnf, `~space* "a" ~space* "b" ~space*`) {
+		t.Errorf("EBNF writes %s", ebnf)
+	}
+	if !strings.Contains(lexer.Diagrams()[0].SVG, ">skip<") {
+		t.Error("The diagram does not show the skipping")
+	}
+	if err := NewGrammar(lexer).Skip(garbage).Validate(); err != nil {
+		t.Errorf("Validate does not know the skipped text: %s", err)
+	}
+}
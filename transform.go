@@ -0,0 +1,67 @@
+package abstract
+
+//// Non-mutating Tree Operations.
+
+// Clone makes a deep copy of the tree, tokens included, so that
+// mutating the copy (with Rule, Between, Filter, Apply...) leaves
+// the original and the Result it came from untouched.
+func (self *Abstract) Clone() *Abstract {
+	if self == nil {
+		return nil
+	}
+	clone := *self
+	if self.Token != nil {
+		token := *self.Token
+		clone.Token = &token
+	}
+	clone.Children = make([]*Abstract, len(self.Children))
+	for i, child := range self.Children {
+		clone.Children[i] = child.Clone()
+	}
+	clone.Leading = cloneTokens(self.Leading)
+	clone.Trailing = cloneTokens(self.Trailing)
+	clone.closing = self.closing.Clone()
+	return &clone
+}
+
+func cloneTokens(tokens []*Token) []*Token {
+	if tokens == nil {
+		return nil
+	}
+	out := make([]*Token, len(tokens))
+	for i, tok := range tokens {
+		token := *tok
+		out[i] = &token
+	}
+	return out
+}
+
+// Transform returns a new tree without modifying this one. Like Walk,
+// it visits the children before their parent; f receives each node with
+// its children already transformed and returns the node to put in its place,
+// or nil to drop it.
+//
+// f must not mutate the node it is given: it should return it as is, or
+// build a new one. Subtrees that f leaves alone are shared with the
+// original tree instead of being copied.
+func (self *Abstract) Transform(f func(*Abstract) *Abstract) *Abstract {
+	changed := false
+	children := make([]*Abstract, 0, len(self.Children))
+	for _, child := range self.Children {
+		new_child := child.Transform(f)
+		if new_child != child {
+			changed = true
+		}
+		if new_child != nil {
+			children = append(children, new_child)
+		}
+	}
+
+	node := self
+	if changed {
+		copied := *self
+		copied.Children = children
+		node = &copied
+	}
+	return f(node)
+}
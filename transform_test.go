@@ -0,0 +1,64 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	result := And(a, b, c, b, c).MustCompile("abcbc")
+	tree := AbstractFromResult(result)
+	clone := tree.Clone()
+	clone.Operator("b", 1, 1)
+	if len(tree.Children) != 5 {
+		t.Error("Mutating a Clone changes the original tree")
+	}
+
+	clone = tree.Clone()
+	clone.Children[0].Token.Value = "z"
+	if tree.Children[0].Token.Value != "a" || result.Tokens()[0].Value != "a" {
+		t.Error("Clone shares tokens with the original tree")
+	}
+	if !tree.Clone().Equal(tree) {
+		t.Error("Clone is not Equal to the original tree")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tree := arithmeticTree("1+2*3")
+	before := tree.String()
+
+	doubled := tree.Transform(func(node *Abstract) *Abstract {
+		if node.Token != nil && node.Token.Name == "3" {
+			return AbstractFromToken(&Token{Name: "6", Value: "6"})
+		}
+		return node
+	})
+	if tree.String() != before {
+		t.Error("Transform mutates the original tree")
+	}
+	if !doubled.Equal(arithmeticTree("1+2*6")) {
+		t.Errorf("Transform gives the wrong tree: %s", doubled)
+	}
+
+	// The left operand of + doesn't contain the 3, so it is shared.
+	if doubled.Children[0].Children[0] != tree.Children[0].Children[0] {
+		t.Error("Transform copies subtrees it did not change")
+	}
+	if doubled.Children[0].Children[1] == tree.Children[0].Children[1] {
+		t.Error("Transform shares subtrees it did change")
+	}
+
+	if tree.Transform(func(node *Abstract) *Abstract { return node }) != tree {
+		t.Error("The identity Transform does not return the same tree")
+	}
+
+	dropped := tree.Transform(func(node *Abstract) *Abstract {
+		if node.Token != nil && node.Token.Name == "*" {
+			return nil
+		}
+		return node
+	})
+	if len(dropped.Children[0].Children[1].Children) != 0 {
+		t.Error("Transform does not drop nodes when f returns nil")
+	}
+}
@@ -0,0 +1,97 @@
+package abstract
+
+import (
+	"bytes"
+)
+
+//// Concrete Syntax Trees.
+
+// Trivia is Filter for a tree that has to be turned back into source text:
+// instead of throwing away garbage tokens and the tokens with the given names,
+// it keeps them as Leading trivia of the token that follows them.
+// Trivia at the very end is kept as Trailing trivia of the last token.
+//
+// Call it before Between and Rule, which then carry the trivia along.
+func (self *Abstract) Trivia(names ...string) {
+	is_trivia := func(abstract *Abstract) bool {
+		if abstract.Token == nil {
+			return false
+		}
+		if abstract.Token.Name == garbage {
+			return true
+		}
+		for _, name := range names {
+			if abstract.Token.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	self.Walk(func(abstract *Abstract) {
+		children := make([]*Abstract, 0, len(abstract.Children))
+		pending := []*Token{}
+
+		for _, child := range abstract.Children {
+			if is_trivia(child) {
+				pending = append(pending, child.Leading...)
+				pending = append(pending, child.Token)
+				pending = append(pending, child.Trailing...)
+				continue
+			}
+			if len(pending) > 0 {
+				child.Leading = append(pending, child.Leading...)
+				pending = []*Token{}
+			}
+			children = append(children, child)
+		}
+
+		if len(pending) > 0 {
+			if len(children) > 0 {
+				last := children[len(children)-1]
+				last.Trailing = append(last.Trailing, pending...)
+			} else {
+				abstract.Trailing = append(abstract.Trailing, pending...)
+			}
+		}
+		abstract.Children = children
+	})
+}
+
+// Source gives back the text the tree was lexed from, trivia included.
+// For a tree built with Trivia, Between and Rule from a single Result,
+// this is exactly the input given to the Lexer. Tokens that were added
+// by hand are written out using their value.
+func (self *Abstract) Source() string {
+	var out bytes.Buffer
+	self.writeSource(&out)
+	return out.String()
+}
+
+func (self *Abstract) writeSource(out *bytes.Buffer) {
+	if self.infix && len(self.Children) == 2 {
+		self.Children[0].writeSource(out)
+		self.writeToken(out)
+		self.Children[1].writeSource(out)
+		return
+	}
+	self.writeToken(out)
+	for _, child := range self.Children {
+		child.writeSource(out)
+	}
+	if self.closing != nil {
+		self.closing.writeSource(out)
+	}
+}
+
+func (self *Abstract) writeToken(out *bytes.Buffer) {
+	for _, tok := range self.Leading {
+		out.WriteString(tok.source())
+	}
+	if self.Token != nil {
+		out.WriteString(self.Token.source())
+	}
+	for _, tok := range self.Trailing {
+		out.WriteString(tok.source())
+	}
+}
@@ -0,0 +1,54 @@
+package abstract
+
+import (
+	"testing"
+)
+
+func TestTrivia(t *testing.T) {
+	spaces := Munch(Space).Alias("space")
+	number := Many(Digit).Alias("number")
+	lexer := Many(OneOf(number, OneOfString("(", ")", "+", "-", "*"), spaces))
+	str := " 2 + (20 *4 )- 1\n"
+
+	tree := AbstractFromResult(lexer.MustCompile(str))
+	tree.Trivia("space")
+	if len(tree.Children) != 9 {
+		t.Errorf("Trivia leaves %d tokens instead of 9", len(tree.Children))
+	}
+	if len(tree.Children[0].Leading) != 1 || tree.Children[0].Leading[0].Value != " " {
+		t.Error("Trivia does not attach spaces to the following token")
+	}
+	if len(tree.Children[8].Trailing) != 1 || tree.Children[8].Trailing[0].Value != "\n" {
+		t.Error("Trivia does not attach spaces at the end to the last token")
+	}
+	if tree.Source() != str {
+		t.Errorf("Source gives %q instead of %q", tree.Source(), str)
+	}
+
+	tree.Between("(", ")")
+	tree.Operator("*", 1, 1)
+	tree.Rule(Operator("+", 1, 1), Operator("-", 1, 1))
+	if tree.Source() != str {
+		t.Errorf("Source gives %q instead of %q after Between and Rule", tree.Source(), str)
+	}
+	if tree.Clone().Source() != str {
+		t.Error("Clone loses trivia")
+	}
+}
+
+func TestGarbageTrivia(t *testing.T) {
+	spaces := Maybe(Munch(Space)).Garbage()
+	number := And(Munch(Digit), Maybe(Munch(And(Lex("_").Garbage(), Munch(Digit))))).Alias("number")
+	lexer := And(spaces, number, Munch(And(spaces, Lex("+"), spaces, number)), spaces)
+	str := "1_000 +  20\t"
+
+	tree := AbstractFromResult(lexer.MustCompile(str))
+	tree.Trivia()
+	tree.Operator("+", 1, 1)
+	if tree.Children[0].Children[0].Children[0].Token.Value != "1000" {
+		t.Error("Trivia changes the value of aliased tokens")
+	}
+	if tree.Source() != str {
+		t.Errorf("Source gives %q instead of %q", tree.Source(), str)
+	}
+}
@@ -0,0 +1,75 @@
+package abstract
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//// Unicode.
+
+// Any letter, number or punctuation, in any script.
+var Letter *Lexer = FromTable(unicode.Letter)
+var Number *Lexer = FromTable(unicode.Number)
+var Punct *Lexer = FromTable(unicode.Punct)
+
+// Whether str starts with prefix, ending on a character boundary of str.
+func hasRunePrefix(str string, prefix string) bool {
+	if !strings.HasPrefix(str, prefix) {
+		return false
+	}
+	return len(str) == len(prefix) || utf8.RuneStart(str[len(prefix)])
+}
+
+// The characters of a unicode table, as ranges.
+func tableRanges(table *unicode.RangeTable) []runeRange {
+	ranges := []runeRange{}
+	add := func(lo rune, hi rune, stride rune) {
+		if stride == 1 {
+			ranges = append(ranges, runeRange{lo, hi})
+			return
+		}
+		for r := lo; r <= hi; r += stride {
+			ranges = append(ranges, runeRange{r, r})
+		}
+	}
+	for _, r := range table.R16 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	for _, r := range table.R32 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	return ranges
+}
+
+// Looks name up like regexp's \p{name}: a category, a script or a property.
+func lookupTable(name string) (*unicode.RangeTable, bool) {
+	if table, ok := unicode.Categories[name]; ok {
+		return table, true
+	}
+	if table, ok := unicode.Scripts[name]; ok {
+		return table, true
+	}
+	table, ok := unicode.Properties[name]
+	return table, ok
+}
+
+// FromTable matches any one character from the tables, like unicode.IsOneOf.
+func FromTable(tables ...*unicode.RangeTable) *Lexer {
+	ranges := []runeRange{}
+	for _, table := range tables {
+		ranges = append(ranges, tableRanges(table)...)
+	}
+	return classLexer(newClass(ranges...))
+}
+
+// Category matches any one character of a Unicode category, script or
+// property, named as in the unicode package: "Lu", "Nd", "Greek", "Han",
+// "White_Space"...
+func Category(name string) *Lexer {
+	table, ok := lookupTable(name)
+	if !ok {
+		panic("Category takes the name of a Unicode category, script or property, like \"Lu\" or \"Greek\".")
+	}
+	return FromTable(table)
+}
@@ -0,0 +1,55 @@
+package abstract
+
+import (
+	"testing"
+	"unicode"
+)
+
+func TestUnicodeClasses(t *testing.T) {
+	ident := And(Letter, Maybe(Munch(OneOf(Letter, Number, Lex("_"))))).Alias("ident")
+	result := ident.MustCompile("größe_2")
+	if result.Tokens()[0].Value != "größe_2" {
+		t.Errorf("Letter and Number don't match non-ASCII characters: %v", result.Tokens())
+	}
+	if !Space.Match(" ") || !Punct.Match("¿") || Letter.Match("1") {
+		t.Error("The Unicode classes are wrong")
+	}
+	if !Category("Greek").Match("λ") || Category("Greek").Match("l") || !Category("Lu").Match("Ä") {
+		t.Error("Category is wrong")
+	}
+	if !FromTable(unicode.Han, unicode.Hiragana).Match("漢") {
+		t.Error("FromTable is wrong")
+	}
+	if Any().Match("\xff") {
+		t.Error("Classes match invalid UTF-8")
+	}
+}
+
+func TestRuneBoundaries(t *testing.T) {
+	// "\xc3" is the first byte of "é", which must not be split.
+	if Lex("\xc3").Match("é") {
+		t.Error("Lex matches part of a character")
+	}
+	result := And(Lex("é"), Lex("t"), Lex("é")).MustCompile("été")
+	if len(result.Tokens()) != 3 || result.Tokens()[2].Value != "é" {
+		t.Errorf("Lex does not match whole characters: %v", result.Tokens())
+	}
+}
+
+func TestCategoryRules(t *testing.T) {
+	rules, err := LoadRules(`word = [\p{Greek}\p{Lu}_]+ ; name = letter (letter | number)* ;`)
+	if err != nil {
+		t.Fatalf("LoadRules fails: %s", err)
+	}
+	if !rules["word"].Match("ΑλφαB") || rules["word"].Match("a") {
+		t.Error("\\p{} classes don't load")
+	}
+	if !rules["name"].Match("日本2") {
+		t.Error("The letter and number rules don't load")
+	}
+
+	_, err = LoadRules(`a = [\p{Klingon}] ;`)
+	if err == nil || err.Error() != `1:6: unknown Unicode category or script "Klingon"` {
+		t.Errorf("LoadRules accepts unknown categories: %v", err)
+	}
+}